@@ -0,0 +1,336 @@
+package deadweight
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+// shutdownTimeout bounds the time given to the language servers to exit when
+// they are restarted.
+const shutdownTimeout = 5 * time.Second
+
+// Options configures an analysis.
+type Options struct {
+	// Root is the absolute path of the analyzed directory.
+	Root string
+	// Files lists the files to analyze, WalkFiles when nil.
+	Files FileSource
+	// Servers are the language servers that can be used, only the ones
+	// handling at least one file are started. The go server when empty.
+	Servers      []LanguageServer
+	Rules        Rules
+	Reachability Reachability
+	TestOnly     TestOnlyPolicy
+	Client       ClientOptions
+	// Overlay holds the content of the files that differ from the disk, keyed
+	// by path relative to the root. The cache is not used when it is set.
+	Overlay map[string][]byte
+	// Baseline findings are not reported, nil disables it.
+	Baseline *Baseline
+	// Diff restricts the report to the symbols relevant to a change, nil
+	// disables it.
+	Diff *Diff
+	// OnFinding, when set, is called with every reported finding as soon as
+	// the references of its symbol are resolved, before the report is
+	// complete. Calls are serialized and hold back the processing of the
+	// responses. Methods may be used through an interface, and in
+	// reachability mode findings depend on every reference, they are passed
+	// once all of them are resolved.
+	OnFinding func(Finding)
+}
+
+// Analyzer reports the unused symbols of a directory. The language servers
+// are started by the first analysis and kept running for the next ones, as
+// long as the root, servers and client options do not change; Close stops
+// them.
+type Analyzer struct {
+	logger *slog.Logger
+
+	workspace *Workspace
+	key       workspaceKey
+	overlay   map[string][]byte
+}
+
+type workspaceKey struct {
+	root        string
+	servers     []LanguageServer
+	timeouts    Timeouts
+	maxInFlight int
+}
+
+// NewAnalyzer creates an analyzer logging to logger, nil discards the logs.
+func NewAnalyzer(logger *slog.Logger) *Analyzer {
+	return &Analyzer{
+		logger: cmp.Or(logger, discardLogger),
+	}
+}
+
+// Analyze reports the unused symbols of opts.Root. When ctx is cancelled the
+// pending requests are cancelled and the partial report is returned with
+// Interrupted set. An Analyzer must not be used concurrently.
+func (a *Analyzer) Analyze(ctx context.Context, opts Options) (*Report, error) {
+	if err := opts.TestOnly.validate(); err != nil {
+		return nil, err
+	}
+	rules := opts.Rules.WithOverlay(opts.Root, opts.Overlay)
+	servers := opts.Servers
+	if len(servers) == 0 {
+		ls, err := LookupLanguageServer(defaultLanguageServers, DefaultLanguage)
+		if err != nil {
+			return nil, err
+		}
+		servers = []LanguageServer{ls}
+	}
+
+	source := opts.Files
+	if source == nil {
+		source = WalkFiles{}
+	}
+	files, err := source.Files(opts.Root, servers, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	servers = DetectLanguageServers(servers, files)
+
+	cache := opts.Client.Cache
+	if len(opts.Overlay) > 0 {
+		// cached results are keyed by the content on disk
+		cache = nil
+	}
+	if cache != nil {
+		if err := cache.Index(opts.Root, files, servers); err != nil {
+			return nil, fmt.Errorf("failed to index files for the cache: %w", err)
+		}
+	}
+
+	if err := a.start(ctx, opts.Root, servers, rules, opts.Client); err != nil {
+		return nil, err
+	}
+	a.workspace.SetRules(rules)
+	a.workspace.SetCache(cache)
+	if err := a.syncOverlay(opts.Overlay); err != nil {
+		return nil, err
+	}
+
+	allSymbols, err := a.workspace.ListDocumentSymbols(ctx, files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document symbols: %w", err)
+	}
+
+	symbols := allSymbols
+	if opts.Diff != nil && !opts.Reachability.Enabled {
+		// other symbols are filtered out of the report anyway
+		symbols = opts.Diff.FilterSymbols(allSymbols)
+	}
+
+	var onResolved ReferencesFunc
+	if opts.OnFinding != nil && !opts.Reachability.Enabled {
+		stream := newFindingStream(opts.OnFinding, opts.Baseline, opts.Diff, newReferenceCounter(a.key.root, rules), opts.TestOnly.countTests())
+		if rules.generatedMode == GeneratedSeparate {
+			stream.separate = rules.generated.is
+		}
+		onResolved = stream.resolved
+	}
+	references, err := a.workspace.ReferencesSymbols(ctx, symbols, onResolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reference symbols: %w", err)
+	}
+	allSymbols.storeErrorsIn(references, func(filePath string, symbol Symbol) bool {
+		return opts.Diff == nil || opts.Diff.Relevant(filePath, symbol)
+	})
+
+	// without reachability only the methods without references can be kept
+	// alive by an interface
+	candidates := symbols
+	if !opts.Reachability.Enabled {
+		candidates = references.GetUnusedSymbols(false)
+	}
+	if err := a.workspace.ImplementationsSymbols(ctx, concreteMethods(candidates, allSymbols), references); err != nil {
+		return nil, fmt.Errorf("failed to resolve implementations: %w", err)
+	}
+	if err := a.workspace.Err(); err != nil {
+		return nil, fmt.Errorf("language server failed: %w", err)
+	}
+
+	unusedSymbols := a.unusedSymbols(references, opts.Reachability, opts.TestOnly.countTests())
+	report := NewReport(references, unusedSymbols)
+	report.Findings, report.TestHelpers = partition(report.Findings, isTestFile)
+	for i := range report.TestHelpers {
+		report.TestHelpers[i].TestHelper = true
+	}
+	if rules.generatedMode == GeneratedSeparate {
+		report.Findings, report.Generated = partition(report.Findings, rules.generated.is)
+		for i := range report.Generated {
+			report.Generated[i].Generated = true
+		}
+	}
+	if opts.TestOnly.Mode == TestOnlySeparate {
+		testOnly := a.unusedSymbols(references, opts.Reachability, false).without(unusedSymbols)
+		report.TestOnly = newFindings(references, testOnly)
+		severity := cmp.Or(opts.TestOnly.Severity, defaultTestOnlySeverity)
+		for i := range report.TestOnly {
+			report.TestOnly[i].Severity = severity
+			report.TestOnly[i].TestOnly = true
+		}
+	}
+	report.Symbols = allSymbols
+
+	suppressedReferences, err := a.workspace.ReferencesSymbols(ctx, rules.SuppressedSymbols(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reference suppressed symbols: %w", err)
+	}
+	report.StaleDirectives = rules.StaleDirectives(suppressedReferences)
+
+	report.Interrupted = ctx.Err() != nil
+	if cache != nil && !report.Interrupted {
+		if err := cache.Save(); err != nil {
+			a.logger.Error("failed to save cache", slog.Any("error", err))
+		}
+	}
+
+	if opts.Baseline != nil {
+		report.ApplyBaseline(*opts.Baseline)
+	}
+	if opts.Diff != nil {
+		report.ApplyDiff(*opts.Diff)
+	}
+	if opts.OnFinding != nil {
+		for _, f := range report.Findings {
+			// the other findings were streamed
+			if opts.Reachability.Enabled || f.Symbol.Kind == lsp.SymbolKindMethod {
+				opts.OnFinding(f)
+			}
+		}
+	}
+	return &report, nil
+}
+
+// concreteMethods returns the methods of symbols that are not declared by an
+// interface.
+func concreteMethods(symbols *SymbolMap, allSymbols *SymbolMap) *SymbolMap {
+	all := allSymbols.snapshot()
+	methods := NewSymbolMap()
+	for filePath, fileSymbols := range symbols.snapshot() {
+		for _, s := range fileSymbols {
+			if s.Kind != lsp.SymbolKindMethod {
+				continue
+			}
+			if slices.ContainsFunc(all[filePath], func(container Symbol) bool {
+				return container.Kind == lsp.SymbolKindInterface && container.Name == s.Container
+			}) {
+				continue
+			}
+			methods.Add(filePath, s)
+		}
+	}
+	return methods
+}
+
+// partition separates the findings of the files matching a predicate.
+func partition(findings []Finding, match func(filePath string) bool) ([]Finding, []Finding) {
+	var kept, matched []Finding
+	for _, f := range findings {
+		if match(f.File) {
+			matched = append(matched, f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, matched
+}
+
+func (a *Analyzer) unusedSymbols(references *ReferenceMap, reachability Reachability, countTests bool) *SymbolMap {
+	if reachability.Enabled {
+		return references.GetUnreachableSymbols(a.key.root, reachability, countTests)
+	}
+	return references.GetUnusedSymbols(countTests)
+}
+
+// start runs the language servers, unless the ones of the previous analysis
+// can be reused.
+func (a *Analyzer) start(ctx context.Context, root string, servers []LanguageServer, rules Rules, options ClientOptions) error {
+	key := workspaceKey{
+		root:        "file://" + root,
+		servers:     servers,
+		timeouts:    options.Timeouts,
+		maxInFlight: options.MaxInFlight,
+	}
+	if a.workspace != nil {
+		if reflect.DeepEqual(a.key, key) {
+			return nil
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := a.Close(shutdownCtx); err != nil {
+			return err
+		}
+	}
+
+	options.Logger = a.logger
+	workspace, err := NewWorkspace(ctx, key.root, servers, rules, options)
+	if err != nil {
+		return fmt.Errorf("failed to initialize LSP clients: %w", err)
+	}
+	if err := workspace.RunAndInitialize(ctx); err != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return errors.Join(fmt.Errorf("failed to run LSP clients: %w", err), workspace.Shutdown(shutdownCtx))
+	}
+	a.workspace = workspace
+	a.key = key
+	if ctx.Err() != nil {
+		// the servers may not be initialized, they are not reused
+		a.key = workspaceKey{}
+	}
+	a.overlay = nil
+	return nil
+}
+
+// syncOverlay sends the overlay to the language servers, the files no longer
+// part of it are read from disk again.
+func (a *Analyzer) syncOverlay(overlay map[string][]byte) error {
+	var errs []error
+	for filePath := range a.overlay {
+		if _, ok := overlay[filePath]; !ok {
+			errs = append(errs, a.workspace.CloseDocument(filePath))
+		}
+	}
+	for filePath, content := range overlay {
+		if previous, ok := a.overlay[filePath]; !ok || !bytes.Equal(previous, content) {
+			errs = append(errs, a.workspace.UpdateDocument(filePath, content))
+		}
+	}
+	a.overlay = maps.Clone(overlay)
+	return errors.Join(errs...)
+}
+
+func (a *Analyzer) Stats() []RequestStats {
+	if a.workspace == nil {
+		return nil
+	}
+	return a.workspace.Stats()
+}
+
+// Close stops the language servers, killing them if they are still running
+// when ctx is done.
+func (a *Analyzer) Close(ctx context.Context) error {
+	if a.workspace == nil {
+		return nil
+	}
+	err := a.workspace.Shutdown(ctx)
+	a.workspace = nil
+	if err != nil {
+		return fmt.Errorf("language server failed: %w", err)
+	}
+	return nil
+}
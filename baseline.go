@@ -0,0 +1,115 @@
+package deadweight
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const BaselineVersion = 1
+
+// BaselineEntry identifies a finding without its position so that it
+// survives unrelated edits of the file.
+type BaselineEntry struct {
+	File      string `json:"file"`
+	Container string `json:"container,omitempty"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+}
+
+type Baseline struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+}
+
+func newBaselineEntry(f Finding) BaselineEntry {
+	return BaselineEntry{
+		File:      f.File,
+		Container: f.Container,
+		Name:      f.Name,
+		Kind:      f.Kind,
+	}
+}
+
+func NewBaseline(findings []Finding) Baseline {
+	entries := make([]BaselineEntry, 0, len(findings))
+	for _, f := range findings {
+		entries = append(entries, newBaselineEntry(f))
+	}
+	sortBaselineEntries(entries)
+	return Baseline{
+		Version: BaselineVersion,
+		Entries: entries,
+	}
+}
+
+// Add appends the findings to the baseline.
+func (b *Baseline) Add(findings []Finding) {
+	for _, f := range findings {
+		b.Entries = append(b.Entries, newBaselineEntry(f))
+	}
+	sortBaselineEntries(b.Entries)
+	b.Version = BaselineVersion
+}
+
+func ReadBaseline(r io.Reader) (Baseline, error) {
+	var b Baseline
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return Baseline{}, fmt.Errorf("failed to decode baseline: %w", err)
+	}
+	if b.Version != BaselineVersion {
+		return Baseline{}, fmt.Errorf("unsupported baseline version %d", b.Version)
+	}
+	return b, nil
+}
+
+func (b Baseline) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(b); err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+	return nil
+}
+
+// Filter returns the findings that are not part of the baseline, and the
+// baseline entries that no longer match any finding.
+func (b Baseline) Filter(findings []Finding) ([]Finding, []BaselineEntry) {
+	remaining := make(map[BaselineEntry]int, len(b.Entries))
+	for _, entry := range b.Entries {
+		remaining[entry]++
+	}
+
+	newFindings := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		entry := newBaselineEntry(f)
+		if remaining[entry] > 0 {
+			remaining[entry]--
+			continue
+		}
+		newFindings = append(newFindings, f)
+	}
+
+	var fixed []BaselineEntry
+	for entry, count := range remaining {
+		for range count {
+			fixed = append(fixed, entry)
+		}
+	}
+	sortBaselineEntries(fixed)
+
+	return newFindings, fixed
+}
+
+func sortBaselineEntries(entries []BaselineEntry) {
+	slices.SortFunc(entries, func(a, b BaselineEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Container, b.Container),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Kind, b.Kind),
+		)
+	})
+}
@@ -0,0 +1,335 @@
+package deadweight
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+const (
+	cacheVersion  = 1
+	cacheFileName = "cache.json"
+)
+
+// Cache stores document symbols and references on disk, keyed by the content
+// hash of the file and of the files that can reference its symbols, so that
+// only symbols affected by a change are queried again.
+//
+// The references of an unexported Go symbol are invalidated by a change in its
+// own directory, the ones of an exported Go symbol by a change in its package
+// or in the packages importing it, directly or not, and the other ones by any
+// change. Only the entries used by the last run are kept when saving.
+type Cache struct {
+	dir string
+
+	previous cacheData
+	current  cacheData
+
+	root          string
+	fileHashes    map[string]string
+	dirHashes     map[string]string
+	workspaceHash string
+	// packages is the import graph of the Go packages, nil until indexed.
+	packages  *goPackages
+	importers map[string][]string
+	// scopeHashes memoizes the hash of a Go package and of its importers.
+	scopeHashes map[string]string
+
+	sync.Mutex
+}
+
+type cacheData struct {
+	Version    int                       `json:"version"`
+	Symbols    map[string][]Symbol       `json:"symbols"`
+	References map[string][]lsp.Location `json:"references"`
+}
+
+func newCacheData() cacheData {
+	return cacheData{
+		Version:    cacheVersion,
+		Symbols:    make(map[string][]Symbol),
+		References: make(map[string][]lsp.Location),
+	}
+}
+
+// LoadCache reads the cache stored in dir, a missing or outdated cache is
+// treated as empty.
+func LoadCache(dir string) (*Cache, error) {
+	c := &Cache{
+		dir:      dir,
+		previous: newCacheData(),
+		current:  newCacheData(),
+	}
+	content, err := os.ReadFile(filepath.Join(dir, cacheFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+	var data cacheData
+	if err := json.Unmarshal(content, &data); err != nil || data.Version != cacheVersion {
+		return c, nil
+	}
+	if data.Symbols != nil {
+		c.previous.Symbols = data.Symbols
+	}
+	if data.References != nil {
+		c.previous.References = data.References
+	}
+	return c, nil
+}
+
+// Index hashes every source file of root, the ones whose references can
+// count even when they are not analyzed, and the analyzed files. It must be
+// called before the cache is used.
+func (c *Cache) Index(root string, files []string, servers []LanguageServer) error {
+	handled := func(name string) bool {
+		return slices.ContainsFunc(servers, func(ls LanguageServer) bool { return ls.HandlesFile(name) })
+	}
+
+	fileHashes := make(map[string]string)
+	dirHashers := make(map[string]hash.Hash)
+	packages := newGoPackages(root)
+	index := func(file string) error {
+		content, err := os.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		fileHash := sha256.Sum256(content)
+		fileHashes[file] = hex.EncodeToString(fileHash[:])
+
+		dir := path.Dir(file)
+		if dirHashers[dir] == nil {
+			dirHashers[dir] = sha256.New()
+		}
+		fmt.Fprintf(dirHashers[dir], "%s\x00%x\x00", path.Base(file), fileHash)
+		packages.add(file, content)
+		return nil
+	}
+
+	// hidden and vendor directories are never analyzed nor referencing
+	if err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filePath != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		if d.Name() == "go.mod" {
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", relPath, err)
+			}
+			packages.add(filepath.ToSlash(relPath), content)
+		}
+		if !handled(d.Name()) {
+			return nil
+		}
+		return index(filepath.ToSlash(relPath))
+	}); err != nil {
+		return fmt.Errorf("failed to walk directory: %w", err)
+	}
+	for _, file := range files {
+		if _, ok := fileHashes[filepath.ToSlash(file)]; !ok {
+			if err := index(filepath.ToSlash(file)); err != nil {
+				return err
+			}
+		}
+	}
+
+	dirHashes := make(map[string]string, len(dirHashers))
+	for dir, dirHash := range dirHashers {
+		dirHashes[dir] = hex.EncodeToString(dirHash.Sum(nil))
+	}
+	workspaceHash := sha256.New()
+	fmt.Fprintf(workspaceHash, "%s\x00", root)
+	for _, dir := range slices.Sorted(maps.Keys(dirHashes)) {
+		fmt.Fprintf(workspaceHash, "%s\x00%s\x00", dir, dirHashes[dir])
+	}
+
+	defer c.Unlock()
+	c.Lock()
+	c.root = root
+	c.fileHashes = fileHashes
+	c.dirHashes = dirHashes
+	c.workspaceHash = hex.EncodeToString(workspaceHash.Sum(nil))
+	c.packages = packages
+	c.importers = packages.importers()
+	c.scopeHashes = make(map[string]string)
+	return nil
+}
+
+// scopeHash hashes the Go package of a directory and the packages importing
+// it, directly or not, the whole workspace when the package is not part of a
+// module.
+func (c *Cache) scopeHash(dir string) string {
+	if _, ok := c.packages.importPath(dir); !ok {
+		return c.workspaceHash
+	}
+	if scopeHash, ok := c.scopeHashes[dir]; ok {
+		return scopeHash
+	}
+	scope := []string{dir}
+	for i := 0; i < len(scope); i++ {
+		for _, importer := range c.importers[scope[i]] {
+			if !slices.Contains(scope, importer) {
+				scope = append(scope, importer)
+			}
+		}
+	}
+	slices.Sort(scope)
+
+	scopeHash := sha256.New()
+	fmt.Fprintf(scopeHash, "%s\x00", c.root)
+	for _, dir := range scope {
+		fmt.Fprintf(scopeHash, "%s\x00%s\x00", dir, c.dirHashes[dir])
+	}
+	c.scopeHashes[dir] = hex.EncodeToString(scopeHash.Sum(nil))
+	return c.scopeHashes[dir]
+}
+
+func (c *Cache) symbolsKey(server LanguageServer, filePath string) (string, bool) {
+	fileHash, ok := c.fileHashes[filepath.ToSlash(filePath)]
+	if !ok {
+		return "", false
+	}
+	return strings.Join([]string{server.Name, server.Command, filePath, fileHash}, "\x00"), true
+}
+
+func (c *Cache) referencesKey(server LanguageServer, filePath string, symbol Symbol) (string, bool) {
+	symbolsKey, ok := c.symbolsKey(server, filePath)
+	if !ok {
+		return "", false
+	}
+	scopeHash := c.workspaceHash
+	if dir := path.Dir(filepath.ToSlash(filePath)); server.LanguageID == "go" && isExported(symbol.Name) {
+		scopeHash = c.scopeHash(dir)
+	} else if server.LanguageID == "go" {
+		scopeHash = c.dirHashes[dir]
+	}
+	return strings.Join([]string{
+		symbolsKey,
+		scopeHash,
+		symbol.Name,
+		symbol.Kind.String(),
+		fmt.Sprintf("%d:%d", symbol.Position.Line, symbol.Position.Character),
+	}, "\x00"), true
+}
+
+// Symbols returns the document symbols of a file, before any rule is applied.
+func (c *Cache) Symbols(server LanguageServer, filePath string) ([]Symbol, bool) {
+	if c == nil {
+		return nil, false
+	}
+	defer c.Unlock()
+	c.Lock()
+	key, ok := c.symbolsKey(server, filePath)
+	if !ok {
+		return nil, false
+	}
+	symbols, ok := c.current.Symbols[key]
+	if !ok {
+		symbols, ok = c.previous.Symbols[key]
+	}
+	if ok {
+		c.current.Symbols[key] = symbols
+	}
+	return symbols, ok
+}
+
+func (c *Cache) PutSymbols(server LanguageServer, filePath string, symbols []Symbol) {
+	if c == nil {
+		return
+	}
+	defer c.Unlock()
+	c.Lock()
+	if key, ok := c.symbolsKey(server, filePath); ok {
+		c.current.Symbols[key] = symbols
+	}
+}
+
+func (c *Cache) References(server LanguageServer, filePath string, symbol Symbol) ([]lsp.Location, bool) {
+	if c == nil {
+		return nil, false
+	}
+	defer c.Unlock()
+	c.Lock()
+	key, ok := c.referencesKey(server, filePath, symbol)
+	if !ok {
+		return nil, false
+	}
+	references, ok := c.current.References[key]
+	if !ok {
+		references, ok = c.previous.References[key]
+	}
+	if ok {
+		c.current.References[key] = references
+	}
+	return references, ok
+}
+
+func (c *Cache) PutReferences(server LanguageServer, filePath string, symbol Symbol, references []lsp.Location) {
+	if c == nil {
+		return
+	}
+	defer c.Unlock()
+	c.Lock()
+	if key, ok := c.referencesKey(server, filePath, symbol); ok {
+		c.current.References[key] = references
+	}
+}
+
+// Save writes the entries used since the cache was loaded.
+func (c *Cache) Save() error {
+	defer c.Unlock()
+	c.Lock()
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	content, err := json.Marshal(c.current)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, cacheFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, cacheFileName)); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
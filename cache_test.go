@@ -0,0 +1,95 @@
+package deadweight
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+func TestCacheReferencesKeyScope(t *testing.T) {
+	root := t.TempDir()
+	write := func(file, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.25\n")
+	write("a/a.go", "package a\n\ntype T1 struct{}\n\nfunc (T1) Foo() {}\n\ntype T2 struct{}\n\nfunc (T2) Foo() {}\n\nfunc helper() {}\n")
+	// b never spells Foo, c never imports a
+	write("b/b.go", "package b\n\nimport \"example.com/m/a\"\n\nfunc Get() a.T1 { return a.T1{} }\n")
+	write("c/c.go", "package c\n\nimport \"example.com/m/b\"\n\nfunc C() { b.Get().Foo() }\n")
+	write("d/d.go", "package d\n\nfunc D() {}\n")
+
+	server := defaultLanguageServers[0]
+	files := []string{"a/a.go", "b/b.go", "c/c.go", "d/d.go"}
+	foo := Symbol{Name: "(T1).Foo", Kind: lsp.SymbolKindMethod, Position: lsp.Position{Line: 4, Character: 10}}
+	helper := Symbol{Name: "helper", Kind: lsp.SymbolKindFunction, Position: lsp.Position{Line: 10, Character: 5}}
+	keys := func() (string, string) {
+		t.Helper()
+		cache := &Cache{previous: newCacheData(), current: newCacheData()}
+		if err := cache.Index(root, files, []LanguageServer{server}); err != nil {
+			t.Fatal(err)
+		}
+		fooKey, _ := cache.referencesKey(server, "a/a.go", foo)
+		helperKey, _ := cache.referencesKey(server, "a/a.go", helper)
+		return fooKey, helperKey
+	}
+
+	fooKey, helperKey := keys()
+	for _, tc := range []struct {
+		name          string
+		file, content string
+		invalidated   bool
+	}{
+		{
+			name:    "unrelated package",
+			file:    "d/d.go",
+			content: "package d\n\nfunc D() { _ = 1 }\n",
+		},
+		{
+			name:        "type change in an importer",
+			file:        "b/b.go",
+			content:     "package b\n\nimport \"example.com/m/a\"\n\nfunc Get() a.T2 { return a.T2{} }\n",
+			invalidated: true,
+		},
+		{
+			name:        "transitive importer",
+			file:        "c/c.go",
+			content:     "package c\n\nimport \"example.com/m/b\"\n\nfunc C() {}\n",
+			invalidated: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			write(tc.file, tc.content)
+			newFooKey, newHelperKey := keys()
+			if (newFooKey != fooKey) != tc.invalidated {
+				t.Errorf("exported symbol invalidated = %v, want %v", newFooKey != fooKey, tc.invalidated)
+			}
+			if newHelperKey != helperKey {
+				t.Error("unexported symbol invalidated by a change in another package")
+			}
+			fooKey = newFooKey
+		})
+	}
+}
+
+func TestGoPackagesImportPath(t *testing.T) {
+	gp := &goPackages{modules: map[string]string{".": "example.com/m", "nested": "example.com/nested"}}
+	for dir, want := range map[string]string{
+		".":             "example.com/m",
+		"a/b":           "example.com/m/a/b",
+		"nested":        "example.com/nested",
+		"nested/sub":    "example.com/nested/sub",
+		"nestedsibling": "example.com/m/nestedsibling",
+	} {
+		if got, ok := gp.importPath(dir); !ok || got != want {
+			t.Errorf("importPath(%q) = %q, %v, want %q", dir, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package deadweight
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type ClientOptions struct {
+	Timeouts Timeouts
+	// MaxInFlight bounds the number of requests waiting for a response, zero
+	// means unbounded.
+	MaxInFlight int
+	// Cache is used to skip requests whose result is known, it can be nil.
+	Cache *Cache
+	// Logger receives the logs of the clients, nil discards them.
+	Logger *slog.Logger
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// Timeouts bounds the time a request can stay unanswered, a zero duration
+// means no timeout.
+type Timeouts struct {
+	// Default applies to the methods without their own timeout, except the
+	// lifecycle ones.
+	Default time.Duration
+	Methods map[string]time.Duration
+}
+
+// lifecycleMethods start and stop the servers, they can take much longer than
+// a query and only time out with their own timeout.
+var lifecycleMethods = []string{"initialize", "shutdown"}
+
+func (t Timeouts) For(method string) time.Duration {
+	if timeout, ok := t.Methods[method]; ok {
+		return timeout
+	}
+	if slices.Contains(lifecycleMethods, method) {
+		return 0
+	}
+	return t.Default
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/theo303/deadweight"
+)
+
+const defaultBaselineFile = ".deadweight-baseline.json"
+
+func baselinePath(current string) string {
+	if baselineFlag != nil && *baselineFlag != "" {
+		return *baselineFlag
+	}
+	return filepath.Join(current, defaultBaselineFile)
+}
+
+func loadBaseline(current string) (deadweight.Baseline, bool, error) {
+	path := baselinePath(current)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && *baselineFlag == "" {
+			return deadweight.Baseline{}, false, nil
+		}
+		return deadweight.Baseline{}, false, fmt.Errorf("opening baseline file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	baseline, err := deadweight.ReadBaseline(f)
+	if err != nil {
+		return deadweight.Baseline{}, false, fmt.Errorf("reading baseline file %s: %w", path, err)
+	}
+	return baseline, true, nil
+}
+
+func writeBaseline(path string, baseline deadweight.Baseline) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating baseline file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := baseline.Write(f); err != nil {
+		return fmt.Errorf("writing baseline file %s: %w", path, err)
+	}
+	return nil
+}
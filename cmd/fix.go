@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/theo303/deadweight"
+)
+
+var dryRunFlag = flag.Bool("dry-run", false, "with the fix command, print a unified diff instead of writing files")
+
+// fix removes the declarations of the reported symbols, then analyzes the
+// files again until nothing more can be removed. The changes are kept in an
+// overlay sent to the language servers, and written once done or printed as a
+// diff with dryRun.
+func fix(current string, dryRun bool, run func(overlay map[string][]byte) (*deadweight.Report, error)) error {
+	original := make(map[string][]byte)
+	overlay := make(map[string][]byte)
+	var skipped []deadweight.Finding
+	for {
+		report, err := run(overlay)
+		if err != nil {
+			return err
+		}
+		if report.Interrupted {
+			return errors.New("interrupted")
+		}
+
+		removedCount := 0
+		skipped = nil
+		findings := make(map[string][]deadweight.Finding)
+		for _, f := range report.Findings {
+			// removing a symbol still referenced from tests or excluded files
+			// would break their build
+			if f.References.Test > 0 || f.References.Excluded > 0 {
+				skipped = append(skipped, f)
+				continue
+			}
+			findings[f.File] = append(findings[f.File], f)
+		}
+		for _, file := range slices.Sorted(maps.Keys(findings)) {
+			content, ok := overlay[file]
+			if !ok {
+				content, err = os.ReadFile(filepath.Join(current, file))
+				if err != nil {
+					return fmt.Errorf("reading %s: %w", file, err)
+				}
+				original[file] = content
+			}
+
+			unused := make([]deadweight.Symbol, 0, len(findings[file]))
+			for _, f := range findings[file] {
+				unused = append(unused, f.Symbol)
+			}
+			fixed, removed, skippedSymbols := deadweight.RemoveDeclarations(content, unused, report.Symbols.File(file))
+			for _, f := range findings[file] {
+				if slices.Contains(skippedSymbols, f.Symbol) {
+					skipped = append(skipped, f)
+				}
+			}
+			if len(removed) == 0 {
+				continue
+			}
+			for _, s := range removed {
+				slog.Info(fmt.Sprintf("removed %s (%s) %s:%d:%d", s.Name, s.Kind.String(), file, s.Position.Line+1, s.Position.Character+1))
+			}
+			removedCount += len(removed)
+			overlay[file] = fixed
+		}
+		if removedCount == 0 {
+			break
+		}
+	}
+
+	if len(skipped) > 0 {
+		slog.Warn("unused symbols that could not be removed:")
+		for _, f := range skipped {
+			slog.Warn(fmt.Sprintf("%s (%s) %s:%d:%d", f.Name, f.Kind, f.File, f.Line, f.Column))
+		}
+	}
+
+	var errs []error
+	for _, file := range slices.Sorted(maps.Keys(overlay)) {
+		if dryRun {
+			fmt.Print(deadweight.UnifiedDiff(file, original[file], overlay[file]))
+			continue
+		}
+		errs = append(errs, writeFile(filepath.Join(current, file), overlay[file]))
+	}
+	return errors.Join(errs...)
+}
+
+func writeFile(path string, content []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, content, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
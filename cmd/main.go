@@ -1,150 +1,411 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
-	"sync"
+	"time"
 
 	"flag"
 
-	yaml "github.com/goccy/go-yaml"
 	"github.com/theo303/deadweight"
+	"github.com/theo303/deadweight/lsp"
 )
 
 var debugFlag = flag.Bool("d", false, "debug mode")
 var configFlag = flag.String("c", "", "config file")
+var formatFlag = flag.String("format", "text", "output format: text, json or sarif")
+var failMaxFlag = flag.Int("fail-max", -1, "exit with code 1 when more than N unused symbols are found (-1 uses the config)")
+var failKindsFlag = flag.String("fail-kinds", "", "comma separated symbol kinds taken into account by -fail-max")
+var reachabilityFlag = flag.Bool("reachability", false, "report symbols that are not reachable from main, init, exported symbols and entrypoints")
+var langFlag = flag.String("lang", "", "comma separated language servers to use, or "+deadweight.LanguageAuto+" to detect them (default "+deadweight.DefaultLanguage+")")
+var timeoutFlag = flag.Duration("timeout", 0, "timeout of every language server request without a configured timeout")
+var jobsFlag = flag.Int("j", 0, "maximum number of requests waiting for a language server response (0 uses the config, unbounded by default)")
+var statsFlag = flag.Bool("stats", false, "print language server request statistics")
+var cacheFlag = flag.Bool("cache", false, "cache language server results between runs")
+var cacheDirFlag = flag.String("cache-dir", "", "cache directory (default "+defaultCacheDir+")")
+var diffFlag = flag.String("diff", "", "only report symbols that became unused in the changes since this git ref")
+var testOnlyFlag = flag.String("test-only", "", "how symbols only used by tests are reported: unused, used or separate (default from the config, unused)")
+var testsFlag = flag.Bool("tests", false, "also analyze test files, their unused symbols are reported separately")
+var streamFlag = flag.Bool("stream", false, "print unused symbols as soon as they are found, text format only")
+var baselineFlag = flag.String("baseline", "", "baseline file (default "+defaultBaselineFile+" when it exists)")
 
-func files(current string) []string {
-	if len(flag.Args()) > 0 {
-		return os.Args[1:]
-	}
+const (
+	exitCodeFindings = 1
+	exitCodeError    = 2
+)
 
-	var goFiles []string
-	if err := filepath.WalkDir(current, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+const shutdownTimeout = 5 * time.Second
 
-		if d.IsDir() {
-			name := d.Name()
-			if strings.HasPrefix(name, ".") || name == "vendor" || strings.Contains(name, "mock") {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+const defaultConfigFile = ".deadweight.yaml"
 
-		if strings.HasSuffix(path, ".go") {
-			if !strings.HasSuffix(path, "_test.go") {
-				goFiles = append(goFiles, strings.TrimPrefix(path, current+"/"))
-			}
-		}
+const defaultCacheDir = ".deadweight/cache"
+
+const (
+	commandBaseline = "baseline"
+	commandFix      = "fix"
+	commandReview   = "review"
+	commandServe    = "serve"
+)
 
-		return nil
-	}); err != nil {
-		slog.Error("failed to walk directory", slog.Any("error", err))
-		os.Exit(1)
+func parseCommand(args []string) (string, []string) {
+	if len(args) > 0 && slices.Contains([]string{commandBaseline, commandFix, commandReview, commandServe}, args[0]) {
+		return args[0], args[1:]
 	}
-	return goFiles
+	return "", args
 }
 
-func loadConfig(current string) (deadweight.Rules, error) {
-	var configFile string
+// configPath returns the config file given with -c, or the default one which
+// may not exist.
+func configPath(current string) string {
 	if configFlag != nil && *configFlag != "" {
-		configFile = *configFlag
-	} else {
-		configFile = filepath.Join(current, ".deadweight.yaml")
+		return *configFlag
+	}
+	return filepath.Join(current, defaultConfigFile)
+}
+
+func loadConfig(current string) (deadweight.Config, error) {
+	configFile := configPath(current)
+	if *configFlag == "" {
 		if _, err := os.Stat(configFile); os.IsNotExist(err) {
 			configFile = ""
 		}
 	}
-	var config deadweight.Config
-	if configFile != "" {
-		content, err := os.ReadFile(configFile)
-		if err != nil {
-			return deadweight.Rules{}, fmt.Errorf("reading config file %s: %w", configFile, err)
-		}
-		if err := yaml.Unmarshal(content, &config); err != nil {
-			return deadweight.Rules{}, fmt.Errorf("unmarshaling config file %s: %w", configFile, err)
-		}
+	if configFile == "" {
+		return deadweight.Config{}, nil
 	}
-	rules, err := config.ToRules()
+	return deadweight.LoadConfig(configFile)
+}
+
+func failPolicy(config deadweight.Config) (deadweight.FailPolicy, error) {
+	policy, err := config.ToFailPolicy()
 	if err != nil {
-		return deadweight.Rules{}, fmt.Errorf("converting config to rules: %w", err)
+		return deadweight.FailPolicy{}, fmt.Errorf("converting config to fail policy: %w", err)
 	}
-	return rules, nil
+	if *failMaxFlag >= 0 {
+		policy.Enabled = true
+		policy.MaxUnused = *failMaxFlag
+	}
+	if *failKindsFlag != "" {
+		var kinds []lsp.SymbolKind
+		for name := range strings.SplitSeq(*failKindsFlag, ",") {
+			kind, err := lsp.ParseSymbolKind(strings.TrimSpace(name))
+			if err != nil {
+				return deadweight.FailPolicy{}, fmt.Errorf("parsing -fail-kinds: %w", err)
+			}
+			kinds = append(kinds, kind)
+		}
+		policy.Enabled = true
+		policy.Kinds = kinds
+	}
+	return policy, nil
 }
 
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 
-	flag.Parse()
+	command, args := parseCommand(os.Args[1:])
+	_ = flag.CommandLine.Parse(args)
 
 	debugMode := debugFlag != nil && *debugFlag
 	if debugMode {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 	}
 
+	format := *formatFlag
+	if format != "text" && format != "json" && format != "sarif" {
+		slog.Error("invalid output format", slog.String("format", format))
+		os.Exit(exitCodeError)
+	}
+	if *streamFlag && format != "text" {
+		slog.Error("-stream only supports the text format", slog.String("format", format))
+		os.Exit(exitCodeError)
+	}
+	if command == commandBaseline && *diffFlag != "" {
+		// the findings outside the diff would be dropped from the baseline
+		slog.Error("-diff cannot be used to write a baseline")
+		os.Exit(exitCodeError)
+	}
+
 	current, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		slog.Error("failed to get working directory", slog.Any("error", err))
+		os.Exit(exitCodeError)
 	}
 
-	rules, err := loadConfig(current)
+	var srv *server
+	if command == commandServe {
+		srv = newServer(os.Stdin, os.Stdout)
+		root, err := srv.initialize()
+		if err != nil {
+			slog.Error("failed to initialize server", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		if root != "" && root != current {
+			if err := os.Chdir(root); err != nil {
+				slog.Error("failed to change directory to the workspace root", slog.Any("error", err))
+				os.Exit(exitCodeError)
+			}
+			current = root
+		}
+	}
+
+	config, err := loadConfig(current)
+	if err != nil {
+		slog.Error("failed to load config", slog.Any("error", err))
+		os.Exit(exitCodeError)
+	}
+	rules, err := config.ToRules()
+	if err != nil {
+		slog.Error("failed to convert config to rules", slog.Any("error", err))
+		os.Exit(exitCodeError)
+	}
+	policy, err := failPolicy(config)
 	if err != nil {
-		panic(fmt.Errorf("loading config:%w", err))
+		slog.Error("failed to load fail policy", slog.Any("error", err))
+		os.Exit(exitCodeError)
+	}
+	reachability, err := config.ToReachability()
+	if err != nil {
+		slog.Error("failed to load reachability roots", slog.Any("error", err))
+		os.Exit(exitCodeError)
+	}
+	if *reachabilityFlag {
+		reachability.Enabled = true
+	}
+	if *testOnlyFlag != "" {
+		config.TestOnly.Mode = *testOnlyFlag
+	}
+	testOnly, err := config.ToTestOnlyPolicy()
+	if err != nil {
+		slog.Error("failed to load test-only policy", slog.Any("error", err))
+		os.Exit(exitCodeError)
 	}
 
-	lc, err := deadweight.NewLSPClient(ctx, "file://"+current, rules)
+	var diff *deadweight.Diff
+	if *diffFlag != "" {
+		d, err := deadweight.GitDiff(ctx, current, *diffFlag)
+		if err != nil {
+			slog.Error("failed to diff", slog.String("ref", *diffFlag), slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		diff = &d
+	}
+
+	if *langFlag != "" {
+		config.Language = ""
+		config.Languages = strings.Split(*langFlag, ",")
+	}
+	servers, err := config.SelectLanguageServers()
 	if err != nil {
-		slog.Error("failed to initialize LSP client", slog.Any("error", err))
-		os.Exit(1)
+		slog.Error("failed to load language servers", slog.Any("error", err))
+		os.Exit(exitCodeError)
 	}
 
-	if err := lc.RunAndInitialize(ctx); err != nil {
-		slog.Error("failed to run LSP client", slog.Any("error", err))
-		os.Exit(1)
+	var files deadweight.FileSource = deadweight.WalkFiles{Tests: *testsFlag || config.IncludeTests}
+	if len(flag.Args()) > 0 {
+		files = deadweight.FileList(flag.Args())
 	}
 
-	allSymbols := deadweight.NewSymbolMap()
-	wg := &sync.WaitGroup{}
+	clientOptions, err := config.ToClientOptions()
+	if err != nil {
+		slog.Error("failed to load client options", slog.Any("error", err))
+		os.Exit(exitCodeError)
+	}
+	if *timeoutFlag > 0 {
+		clientOptions.Timeouts.Default = *timeoutFlag
+	}
+	if *jobsFlag > 0 {
+		clientOptions.MaxInFlight = *jobsFlag
+	}
 
-	files := files(current)
+	cacheDir := cmp.Or(*cacheDirFlag, config.Cache.Dir, filepath.Join(current, defaultCacheDir))
+	if *cacheFlag || config.Cache.Enabled {
+		cache, err := deadweight.LoadCache(cacheDir)
+		if err != nil {
+			slog.Error("failed to load cache", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		clientOptions.Cache = cache
+	}
+
+	opts := deadweight.Options{
+		Root:         current,
+		Files:        files,
+		Servers:      servers,
+		Rules:        rules,
+		Reachability: reachability,
+		TestOnly:     testOnly,
+		Client:       clientOptions,
+		Diff:         diff,
+	}
+	if command != commandBaseline {
+		baseline, ok, err := loadBaseline(current)
+		if err != nil {
+			slog.Error("failed to load baseline", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		if ok {
+			opts.Baseline = &baseline
+		}
+	}
 
-	for _, file := range files {
-		wg.Add(1)
-		go func() {
-			if err := lc.ListDocumentSymbols(file, wg, allSymbols); err != nil {
-				slog.Error("failed to list workspace symbols", slog.Any("error", err))
-				os.Exit(1)
+	analyzer := deadweight.NewAnalyzer(slog.Default())
+	run := func(overlay map[string][]byte) (*deadweight.Report, error) {
+		opts := opts
+		opts.Overlay = overlay
+		report, err := analyzer.Analyze(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		if debugMode {
+			report.Symbols.Print(slog.Default())
+		}
+		return report, nil
+	}
+	// shutdown stops the language servers once the analyses are done.
+	shutdown := func() error {
+		if *statsFlag {
+			for _, stats := range analyzer.Stats() {
+				stats.Print(slog.Default())
 			}
-		}()
+		}
+		stop()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return analyzer.Close(shutdownCtx)
 	}
-	wg.Wait()
 
-	if debugMode {
-		allSymbols.Print()
+	if command == commandServe {
+		// documents change, results on disk cannot be reused
+		opts.Client.Cache = nil
+		err := srv.serve(ctx, current, run)
+		if closeErr := shutdown(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+		if err != nil {
+			slog.Error("failed to serve", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		return
+	}
+
+	if command == commandFix {
+		err := fix(current, *dryRunFlag, run)
+		if closeErr := shutdown(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+		if err != nil {
+			slog.Error("failed to fix", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		return
 	}
 
-	references, err := lc.ReferencesSymbols(allSymbols)
+	stream := *streamFlag && command == ""
+	if stream {
+		streamed := false
+		opts.OnFinding = func(f deadweight.Finding) {
+			if !streamed {
+				slog.Info("unused symbols found:")
+				streamed = true
+			}
+			f.Print(slog.Default())
+		}
+	}
+	report, err := run(nil)
+	if closeErr := shutdown(); closeErr != nil {
+		err = errors.Join(err, closeErr)
+	}
 	if err != nil {
-		slog.Error("failed to reference symbols", slog.Any("error", err))
-		os.Exit(1)
+		slog.Error("failed to analyze", slog.Any("error", err))
+		os.Exit(exitCodeError)
 	}
 
-	unusedSymbols := references.GetUnusedSymbols()
-	if unusedSymbols.Len() > 0 {
-		slog.Info("unused symbols found:")
-		unusedSymbols.Print()
-	} else {
-		slog.Info("no unused symbols found")
+	if command == commandBaseline {
+		if err := writeBaseline(baselinePath(current), deadweight.NewBaseline(report.Findings)); err != nil {
+			slog.Error("failed to write baseline", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		slog.Info("baseline written", slog.String("file", baselinePath(current)), slog.Int("entries", len(report.Findings)))
+		return
 	}
 
-	stop()
-	lc.Wait()
+	if command == commandReview {
+		if err := review(current, report.Findings, report.Symbols, os.Stdin, os.Stdout); err != nil {
+			slog.Error("failed to review findings", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		return
+	}
+
+	switch format {
+	case "json":
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			slog.Error("failed to write report", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+	case "sarif":
+		if err := report.WriteSARIF(os.Stdout, "file://"+current); err != nil {
+			slog.Error("failed to write report", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+	default:
+		// unresolved symbols may be unused, they are listed below
+		unresolved := len(report.Unknown)+len(report.TimedOut) > 0
+		if stream {
+			// the findings were printed as they were found
+			if len(report.Findings) == 0 && !unresolved {
+				slog.Info("no unused symbols found")
+			}
+		} else if len(report.Findings) > 0 {
+			slog.Info("unused symbols found:")
+			report.Print(slog.Default())
+		} else if !unresolved {
+			slog.Info("no unused symbols found")
+		}
+		if len(report.TestHelpers) > 0 {
+			slog.Info("unused symbols in test files:")
+			report.PrintTestHelpers(slog.Default())
+		}
+		if len(report.Generated) > 0 {
+			slog.Info("unused symbols in generated files:")
+			report.PrintGenerated(slog.Default())
+		}
+		if len(report.TestOnly) > 0 {
+			slog.Info("symbols only used by tests:")
+			report.PrintTestOnly(slog.Default())
+		}
+		if len(report.Unknown)+len(report.TimedOut) > 0 {
+			slog.Warn("symbols whose references could not be resolved:")
+			report.PrintUnknown(slog.Default())
+		}
+		if len(report.StaleDirectives) > 0 {
+			slog.Warn("stale deadweight directives found:")
+			report.PrintStaleDirectives(slog.Default())
+		}
+		if len(report.FixedBaseline) > 0 {
+			slog.Info("baseline entries fixed, the baseline can be pruned:")
+			report.PrintFixedBaseline(slog.Default())
+		}
+	}
+
+	if report.Interrupted {
+		slog.Error("interrupted, the report is incomplete")
+		os.Exit(exitCodeError)
+	}
+	if policy.Fails(report.Findings) {
+		os.Exit(exitCodeFindings)
+	}
+	if policy.Enabled && len(report.Unknown)+len(report.TimedOut) > 0 {
+		slog.Error("the references of some symbols could not be resolved, the fail policy cannot be checked")
+		os.Exit(exitCodeError)
+	}
 }
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/theo303/deadweight"
+	"github.com/theo303/deadweight/lsp"
+)
+
+const maxSnippetLines = 15
+
+type reviewAction int
+
+const (
+	reviewSkip reviewAction = iota
+	reviewDelete
+	reviewIgnoreInline
+	reviewBaseline
+	reviewConfigRule
+)
+
+type reviewDecision struct {
+	finding deadweight.Finding
+	action  reviewAction
+	reason  string
+}
+
+// review walks through the findings, asks what to do with each of them and
+// applies the decisions once every finding was reviewed or the user quits.
+func review(current string, findings []deadweight.Finding, symbols *deadweight.SymbolMap, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	var decisions []reviewDecision
+
+	for i, f := range findings {
+		fmt.Fprintf(out, "\n[%d/%d] %s (%s) %s:%d:%d\n", i+1, len(findings), f.Name, f.Kind, f.File, f.Line, f.Column)
+		if err := printSnippet(out, filepath.Join(current, f.File), f.Symbol); err != nil {
+			return err
+		}
+
+		action, ok := askAction(scanner, out)
+		if !ok {
+			break
+		}
+		decision := reviewDecision{finding: f, action: action}
+		if action == reviewIgnoreInline {
+			fmt.Fprint(out, "reason (optional): ")
+			if scanner.Scan() {
+				decision.reason = strings.TrimSpace(scanner.Text())
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading answer: %w", err)
+	}
+
+	return applyDecisions(current, symbols, decisions)
+}
+
+// askAction returns false when the user quits or the input is closed.
+func askAction(scanner *bufio.Scanner, out io.Writer) (reviewAction, bool) {
+	for {
+		fmt.Fprint(out, "[d]elete, [i]gnore inline, add to [b]aseline, add [c]onfig rule, [s]kip, [q]uit? ")
+		if !scanner.Scan() {
+			return reviewSkip, false
+		}
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "d", "delete":
+			return reviewDelete, true
+		case "i", "ignore":
+			return reviewIgnoreInline, true
+		case "b", "baseline":
+			return reviewBaseline, true
+		case "c", "config":
+			return reviewConfigRule, true
+		case "s", "skip", "":
+			return reviewSkip, true
+		case "q", "quit":
+			return reviewSkip, false
+		}
+	}
+}
+
+func printSnippet(out io.Writer, path string, s deadweight.Symbol) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	lines := strings.Split(string(content), "\n")
+	start := min(s.Range.Start.Line, len(lines)-1)
+	end := min(s.Range.End.Line, len(lines)-1, start+maxSnippetLines-1)
+	for line := start; line <= end; line++ {
+		fmt.Fprintf(out, "%6d | %s\n", line+1, lines[line])
+	}
+	if end < s.Range.End.Line {
+		fmt.Fprintf(out, "%6s | ...\n", "")
+	}
+	return nil
+}
+
+func applyDecisions(current string, symbols *deadweight.SymbolMap, decisions []reviewDecision) error {
+	var baselined, configured []deadweight.Finding
+	deleted := make(map[string][]deadweight.Symbol)
+	ignored := make(map[string]map[deadweight.Symbol]string)
+	for _, d := range decisions {
+		f := d.finding
+		switch d.action {
+		case reviewDelete:
+			deleted[f.File] = append(deleted[f.File], f.Symbol)
+		case reviewIgnoreInline:
+			if ignored[f.File] == nil {
+				ignored[f.File] = make(map[deadweight.Symbol]string)
+			}
+			ignored[f.File][f.Symbol] = d.reason
+		case reviewBaseline:
+			baselined = append(baselined, f)
+		case reviewConfigRule:
+			configured = append(configured, f)
+		}
+	}
+
+	var errs []error
+	if len(baselined) > 0 {
+		errs = append(errs, addToBaseline(current, baselined))
+	}
+	if len(configured) > 0 {
+		errs = append(errs, addConfigRules(current, configured))
+	}
+	files := slices.Sorted(maps.Keys(deleted))
+	for file := range ignored {
+		if _, ok := deleted[file]; !ok {
+			files = append(files, file)
+		}
+	}
+	for _, file := range files {
+		errs = append(errs, editFile(filepath.Join(current, file), symbols.File(file), deleted[file], ignored[file]))
+	}
+	return errors.Join(errs...)
+}
+
+// editFile inserts the ignore directives, then removes the deleted
+// declarations whose lines are shifted by the inserted directives.
+func editFile(path string, symbols []deadweight.Symbol, deleted []deadweight.Symbol, ignored map[deadweight.Symbol]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	content, inserted := deadweight.AddIgnoreDirectives(content, ignored)
+	for i := range symbols {
+		symbols[i] = shiftSymbol(symbols[i], inserted)
+	}
+	for i := range deleted {
+		deleted[i] = shiftSymbol(deleted[i], inserted)
+	}
+	content, _, skipped := deadweight.RemoveDeclarations(content, deleted, symbols)
+	for _, s := range skipped {
+		slog.Warn(fmt.Sprintf("%s (%s) %s:%d:%d could not be removed", s.Name, s.Kind.String(), path, s.Position.Line+1, s.Position.Character+1))
+	}
+
+	return writeFile(path, content)
+}
+
+// shiftSymbol moves the positions of a symbol below the lines inserted before
+// them.
+func shiftSymbol(s deadweight.Symbol, inserted []int) deadweight.Symbol {
+	shift := func(p lsp.Position) lsp.Position {
+		n, _ := slices.BinarySearch(inserted, p.Line+1)
+		p.Line += n
+		return p
+	}
+	s.Position = shift(s.Position)
+	s.Range = lsp.Range{Start: shift(s.Range.Start), End: shift(s.Range.End)}
+	s.SelectionRange = lsp.Range{Start: shift(s.SelectionRange.Start), End: shift(s.SelectionRange.End)}
+	return s
+}
+
+func addToBaseline(current string, findings []deadweight.Finding) error {
+	baseline, _, err := loadBaseline(current)
+	if err != nil {
+		return err
+	}
+	baseline.Add(findings)
+	return writeBaseline(baselinePath(current), baseline)
+}
+
+// addConfigRules appends an ignore-symbols rule matching exactly each finding
+// to the config file, keeping its comments.
+func addConfigRules(current string, findings []deadweight.Finding) error {
+	var rules bytes.Buffer
+	for _, f := range findings {
+		fmt.Fprintf(&rules, "- kinds:\n    - %s\n  names:\n    - %q\n  paths:\n    - %q\n", f.Kind, escapeGlob(f.Name), escapeGlob(f.File))
+	}
+
+	path := configPath(current)
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	file, err := parser.ParseBytes(content, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	ignoreSymbols, err := yaml.PathString("$.ignore-symbols")
+	if err != nil {
+		return err
+	}
+	if _, err := ignoreSymbols.FilterFile(file); err == nil {
+		if err := ignoreSymbols.MergeFromReader(file, &rules); err != nil {
+			return fmt.Errorf("adding rules to config file %s: %w", path, err)
+		}
+		content = []byte(strings.TrimSuffix(file.String(), "\n") + "\n")
+	} else if yaml.IsNotFoundNodeError(err) {
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		content = append(content, "ignore-symbols:\n"...)
+		for line := range strings.Lines(rules.String()) {
+			content = append(content, "  "+line...)
+		}
+	} else {
+		return fmt.Errorf("reading ignore-symbols of config file %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("writing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// escapeGlob quotes the characters of a name that have a meaning in patterns.
+func escapeGlob(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theo303/deadweight"
+	"github.com/theo303/deadweight/lsp"
+)
+
+// analysisDelay groups the changes made while typing into one analysis.
+const analysisDelay = 500 * time.Millisecond
+
+// server speaks LSP with an editor over stdio, and publishes the unused
+// symbols as diagnostics.
+type server struct {
+	current string
+	in      *bufio.Reader
+	out     io.Writer
+	writeMu sync.Mutex
+
+	analyze func(overlay map[string][]byte) (*deadweight.Report, error)
+	trigger chan struct{}
+
+	mu sync.Mutex
+	// documents holds the content of the documents open in the editor.
+	documents map[string][]byte
+	// analyzed holds the documents used by the last analysis.
+	analyzed  map[string][]byte
+	findings  map[string][]deadweight.Finding
+	symbols   *deadweight.SymbolMap
+	published map[string]bool
+}
+
+func newServer(in io.Reader, out io.Writer) *server {
+	return &server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		trigger:   make(chan struct{}, 1),
+		documents: make(map[string][]byte),
+		findings:  make(map[string][]deadweight.Finding),
+		published: make(map[string]bool),
+	}
+}
+
+// initialize waits for the initialize request of the editor, answers it and
+// returns the root of the workspace, empty when the editor has none.
+func (s *server) initialize() (string, error) {
+	for {
+		request, err := s.read()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case request.Method == "initialize":
+			var params struct {
+				RootURI          string `json:"rootUri"`
+				WorkspaceFolders []struct {
+					URI string `json:"uri"`
+				} `json:"workspaceFolders"`
+			}
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				return "", fmt.Errorf("decoding initialize params: %w", err)
+			}
+			rootURI := params.RootURI
+			if len(params.WorkspaceFolders) > 0 {
+				rootURI = params.WorkspaceFolders[0].URI
+			}
+
+			s.reply(request, map[string]any{
+				"capabilities": map[string]any{
+					"textDocumentSync": map[string]any{
+						"openClose": true,
+						"change":    1, // full content
+						"save":      map[string]any{},
+					},
+					"codeActionProvider": map[string]any{
+						"codeActionKinds": []string{"quickfix"},
+					},
+				},
+				"serverInfo": map[string]any{"name": "deadweight"},
+			})
+			if rootURI == "" {
+				return "", nil
+			}
+			return uriPath(rootURI)
+		case request.Method == "exit":
+			return "", errors.New("exited before initialization")
+		case !request.IsNotification():
+			s.replyError(request, lsp.ErrorCodeServerNotInitialized, "server not initialized")
+		}
+	}
+}
+
+// serve handles the messages of the editor until it exits, analyzing the
+// workspace whenever a document changes.
+func (s *server) serve(ctx context.Context, current string, analyze func(overlay map[string][]byte) (*deadweight.Report, error)) error {
+	s.current = current
+	s.analyze = analyze
+
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		s.analyzeOnChange(ctx)
+	})
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for {
+		request, err := s.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		switch request.Method {
+		case "initialized", "textDocument/didSave":
+			s.scheduleAnalysis()
+		case "textDocument/didOpen":
+			var params struct {
+				TextDocument struct {
+					URI  string `json:"uri"`
+					Text string `json:"text"`
+				} `json:"textDocument"`
+			}
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				slog.Error("failed to decode didOpen params", slog.Any("error", err))
+				continue
+			}
+			s.updateDocument(params.TextDocument.URI, []byte(params.TextDocument.Text))
+		case "textDocument/didChange":
+			var params struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+				ContentChanges []struct {
+					Text string `json:"text"`
+				} `json:"contentChanges"`
+			}
+			if err := json.Unmarshal(request.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+				slog.Error("failed to decode didChange params", slog.Any("error", err))
+				continue
+			}
+			// the whole content is synchronized, the last change holds it
+			s.updateDocument(params.TextDocument.URI, []byte(params.ContentChanges[len(params.ContentChanges)-1].Text))
+		case "textDocument/didClose":
+			var params struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+			}
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				slog.Error("failed to decode didClose params", slog.Any("error", err))
+				continue
+			}
+			s.closeDocument(params.TextDocument.URI)
+		case "textDocument/codeAction":
+			s.reply(request, s.codeActions(request.Params))
+		case "shutdown":
+			s.reply(request, nil)
+		case "exit":
+			return nil
+		default:
+			if !request.IsNotification() {
+				s.replyError(request, lsp.ErrorCodeMethodNotFound, "method not supported: "+request.Method)
+			}
+		}
+	}
+}
+
+func (s *server) read() (lsp.Request, error) {
+	for {
+		body, err := lsp.ReadMessage(s.in)
+		if err != nil {
+			return lsp.Request{}, err
+		}
+		if len(body) == 0 {
+			continue
+		}
+		var request lsp.Request
+		if err := json.Unmarshal(body, &request); err != nil {
+			slog.Error("failed to unmarshal message", slog.Any("error", err))
+			continue
+		}
+		if request.Method == "" {
+			// responses to requests the server never sends
+			continue
+		}
+		slog.Debug("message received from editor", slog.String("method", request.Method))
+		return request, nil
+	}
+}
+
+func (s *server) write(message any) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("failed to marshal message", slog.Any("error", err))
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := lsp.WriteMessage(s.out, payload); err != nil {
+		slog.Error("failed to write message to editor", slog.Any("error", err))
+	}
+}
+
+func (s *server) reply(request lsp.Request, result any) {
+	s.write(lsp.Response{JSONRPC: "2.0", ID: request.ID, Result: result})
+}
+
+func (s *server) replyError(request lsp.Request, code lsp.ErrorCode, message string) {
+	s.write(lsp.Response{JSONRPC: "2.0", ID: request.ID, Error: &lsp.ResponseError{Code: code, Message: message}})
+}
+
+func (s *server) notify(method string, params any) {
+	s.write(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// relPath returns the path of a document relative to the workspace, false
+// when it is outside of it.
+func (s *server) relPath(uri string) (string, bool) {
+	path, err := uriPath(uri)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(s.current, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (s *server) uri(filePath string) string {
+	return "file://" + s.current + "/" + filePath
+}
+
+func (s *server) updateDocument(uri string, content []byte) {
+	filePath, ok := s.relPath(uri)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.documents[filePath] = content
+	s.mu.Unlock()
+	s.scheduleAnalysis()
+}
+
+func (s *server) closeDocument(uri string) {
+	filePath, ok := s.relPath(uri)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	delete(s.documents, filePath)
+	s.mu.Unlock()
+	s.scheduleAnalysis()
+}
+
+func (s *server) scheduleAnalysis() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *server) analyzeOnChange(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.trigger:
+		}
+		// wait for the editor to settle, changes made meanwhile are part of
+		// this analysis
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(analysisDelay):
+		}
+		select {
+		case <-s.trigger:
+		default:
+		}
+		s.runAnalysis()
+	}
+}
+
+func (s *server) runAnalysis() {
+	s.mu.Lock()
+	overlay := maps.Clone(s.documents)
+	s.mu.Unlock()
+
+	report, err := s.analyze(overlay)
+	if err != nil {
+		slog.Error("failed to analyze", slog.Any("error", err))
+		s.notify("window/showMessage", map[string]any{
+			"type":    1, // error
+			"message": "deadweight: " + err.Error(),
+		})
+		return
+	}
+	if report.Interrupted {
+		return
+	}
+
+	findings := make(map[string][]deadweight.Finding)
+	for _, f := range slices.Concat(report.Findings, report.TestOnly, report.TestHelpers, report.Generated) {
+		findings[f.File] = append(findings[f.File], f)
+	}
+
+	s.mu.Lock()
+	files := slices.Collect(maps.Keys(s.published))
+	for file := range findings {
+		if !s.published[file] {
+			files = append(files, file)
+		}
+	}
+	s.analyzed = overlay
+	s.findings = findings
+	s.symbols = report.Symbols
+	s.published = make(map[string]bool, len(findings))
+	for file := range findings {
+		s.published[file] = true
+	}
+	s.mu.Unlock()
+
+	slices.Sort(files)
+	for _, file := range files {
+		diagnostics := make([]lsp.Diagnostic, 0, len(findings[file]))
+		for _, f := range findings[file] {
+			diagnostics = append(diagnostics, diagnostic(f))
+		}
+		s.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         s.uri(file),
+			"diagnostics": diagnostics,
+		})
+	}
+}
+
+func diagnostic(f deadweight.Finding) lsp.Diagnostic {
+	if f.TestOnly {
+		// the declaration is still needed by the tests, it is not faded out
+		return lsp.Diagnostic{
+			Range:    f.Symbol.SelectionRange,
+			Severity: diagnosticSeverities[f.Severity],
+			Code:     f.RuleID(),
+			Source:   "deadweight",
+			Message:  fmt.Sprintf("%s is only used by tests", f.Name),
+		}
+	}
+	return lsp.Diagnostic{
+		Range:    f.Symbol.SelectionRange,
+		Severity: lsp.DiagnosticSeverityHint,
+		Code:     f.RuleID(),
+		Source:   "deadweight",
+		Message:  fmt.Sprintf("%s is unused", f.Name),
+		Tags:     []lsp.DiagnosticTag{lsp.DiagnosticTagUnnecessary},
+	}
+}
+
+// diagnosticSeverities maps the SARIF levels of the findings to diagnostic
+// severities.
+var diagnosticSeverities = map[string]lsp.DiagnosticSeverity{
+	"error":   lsp.DiagnosticSeverityError,
+	"warning": lsp.DiagnosticSeverityWarning,
+	"note":    lsp.DiagnosticSeverityInformation,
+	"none":    lsp.DiagnosticSeverityHint,
+}
+
+// codeActions offers to delete or suppress the unused symbols of the
+// requested range, as long as the document did not change since the
+// analysis.
+func (s *server) codeActions(rawParams json.RawMessage) []map[string]any {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Range lsp.Range `json:"range"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		slog.Error("failed to decode codeAction params", slog.Any("error", err))
+		return nil
+	}
+	uri := params.TextDocument.URI
+	filePath, ok := s.relPath(uri)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	findings := s.findings[filePath]
+	content, open := s.documents[filePath]
+	analyzed, wasOpen := s.analyzed[filePath]
+	symbols := s.symbols
+	s.mu.Unlock()
+	if len(findings) == 0 || open != wasOpen || !bytes.Equal(content, analyzed) {
+		return nil
+	}
+	if !open {
+		var err error
+		if content, err = os.ReadFile(filepath.Join(s.current, filePath)); err != nil {
+			slog.Error("failed to read document", slog.String("file", filePath), slog.Any("error", err))
+			return nil
+		}
+	}
+
+	actions := []map[string]any{}
+	for _, f := range findings {
+		if !f.Symbol.SelectionRange.Intersects(params.Range) {
+			continue
+		}
+		// removing a symbol used by tests would break them, and generated code
+		// is overwritten by its generator
+		if !f.TestOnly && !f.Generated {
+			if fixed, removed, _ := deadweight.RemoveDeclarations(content, []deadweight.Symbol{f.Symbol}, symbols.File(filePath)); len(removed) > 0 {
+				actions = append(actions, codeAction(fmt.Sprintf("Remove unused %s", f.Name), uri, f, deadweight.LineEdits(content, fixed), true))
+			}
+		}
+		suppressed, _ := deadweight.AddIgnoreDirectives(content, map[deadweight.Symbol]string{f.Symbol: ""})
+		actions = append(actions, codeAction("Suppress with //deadweight:ignore", uri, f, deadweight.LineEdits(content, suppressed), false))
+	}
+	return actions
+}
+
+func codeAction(title, uri string, f deadweight.Finding, edits []lsp.TextEdit, preferred bool) map[string]any {
+	return map[string]any{
+		"title":       title,
+		"kind":        "quickfix",
+		"diagnostics": []lsp.Diagnostic{diagnostic(f)},
+		"isPreferred": preferred,
+		"edit": map[string]any{
+			"changes": map[string][]lsp.TextEdit{uri: edits},
+		},
+	}
+}
+
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("parsing uri %s: %w", uri, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported uri %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
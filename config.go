@@ -2,39 +2,326 @@ package deadweight
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
+	yaml "github.com/goccy/go-yaml"
 	"github.com/theo303/deadweight/lsp"
 )
 
 type Config struct {
-	IgnoreSymbols        []ignoreSymbolsConfig `yaml:"ignore-symbols"`
-	IgnoreEmbeddedFields bool                  `yaml:"ignore-embedded-fields"`
+	Language             string                    `yaml:"language"`
+	Languages            []string                  `yaml:"languages"`
+	LanguageServers      []languageServerConfig    `yaml:"language-servers"`
+	Paths                []string                  `yaml:"paths"`
+	ExcludePaths         []string                  `yaml:"exclude-paths"`
+	IgnoreSymbols        []ignoreSymbolsConfig     `yaml:"ignore-symbols"`
+	IgnoreEmbeddedFields bool                      `yaml:"ignore-embedded-fields"`
+	FailOn               failOnConfig              `yaml:"fail-on"`
+	Reachability         reachabilityConfig        `yaml:"reachability"`
+	Timeouts             map[string]string         `yaml:"timeouts"`
+	MaxInFlight          int                       `yaml:"max-in-flight"`
+	Cache                cacheConfig               `yaml:"cache"`
+	TestOnly             testOnlyConfig            `yaml:"test-only"`
+	IncludeTests         bool                      `yaml:"include-tests"`
+	ExcludeReferences    []excludeReferencesConfig `yaml:"exclude-references"`
+	Generated            generatedConfig           `yaml:"generated"`
+}
+
+type generatedConfig struct {
+	Mode            string `yaml:"mode"`
+	CountReferences *bool  `yaml:"count-references"`
+}
+
+type excludeReferencesConfig struct {
+	Name  string   `yaml:"name"`
+	Paths []string `yaml:"paths"`
+}
+
+type testOnlyConfig struct {
+	Mode     string `yaml:"mode"`
+	Severity string `yaml:"severity"`
+}
+
+type cacheConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+}
+
+type languageServerConfig struct {
+	Name                  string                `yaml:"name"`
+	Command               string                `yaml:"command"`
+	Args                  []string              `yaml:"args"`
+	Extensions            []string              `yaml:"extensions"`
+	LanguageID            string                `yaml:"language-id"`
+	InitializationOptions map[string]any        `yaml:"initialization-options"`
+	OpenDocuments         *bool                 `yaml:"open-documents"`
+	IgnoreSymbols         []ignoreSymbolsConfig `yaml:"ignore-symbols"`
+}
+
+type reachabilityConfig struct {
+	Enabled     bool                  `yaml:"enabled"`
+	Exported    *bool                 `yaml:"exported"`
+	Entrypoints []ignoreSymbolsConfig `yaml:"entrypoints"`
+}
+
+type failOnConfig struct {
+	MaxUnused *int     `yaml:"max-unused"`
+	Kinds     []string `yaml:"kinds"`
 }
 
 type ignoreSymbolsConfig struct {
-	Kinds []string `yaml:"kinds"`
-	Names []string `yaml:"names"`
+	Kinds        []string `yaml:"kinds"`
+	Names        []string `yaml:"names"`
+	Paths        []string `yaml:"paths"`
+	ExcludePaths []string `yaml:"exclude-paths"`
 }
 
-func (c Config) ToRules() (Rules, error) {
-	ignoreSymbols := make([]IgnoreSymbols, 0, len(c.IgnoreSymbols))
-	for _, isc := range c.IgnoreSymbols {
-		var kinds []lsp.SymbolKind
-		for _, symbolName := range isc.Kinds {
-			sk, err := lsp.ParseSymbolKind(symbolName)
-			if err != nil {
-				return Rules{}, fmt.Errorf("invalid symbol kind '%s': %w", symbolName, err)
-			}
-			kinds = append(kinds, sk)
+func toIgnoreSymbols(configs []ignoreSymbolsConfig) ([]IgnoreSymbols, error) {
+	ignoreSymbols := make([]IgnoreSymbols, 0, len(configs))
+	for _, isc := range configs {
+		kinds, err := parseSymbolKinds(isc.Kinds)
+		if err != nil {
+			return nil, err
+		}
+		pathFilter, err := newPathFilter(isc.Paths, isc.ExcludePaths)
+		if err != nil {
+			return nil, err
 		}
 
 		ignoreSymbols = append(ignoreSymbols, IgnoreSymbols{
-			Kinds: kinds,
-			Names: isc.Names,
+			Kinds:      kinds,
+			Names:      isc.Names,
+			PathFilter: pathFilter,
 		})
 	}
+	return ignoreSymbols, nil
+}
+
+// LoadConfig reads a YAML config file.
+func LoadConfig(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (c Config) ToRules() (Rules, error) {
+	ignoreSymbols, err := toIgnoreSymbols(c.IgnoreSymbols)
+	if err != nil {
+		return Rules{}, err
+	}
+	paths, err := newPathFilter(c.Paths, c.ExcludePaths)
+	if err != nil {
+		return Rules{}, err
+	}
+	excludeReferences, err := c.toReferenceExclusions()
+	if err != nil {
+		return Rules{}, err
+	}
+	generatedMode, err := parseGeneratedMode(c.Generated.Mode)
+	if err != nil {
+		return Rules{}, err
+	}
 	return Rules{
 		ignoreSymbols:        ignoreSymbols,
 		ignoreEmbeddedFields: c.IgnoreEmbeddedFields,
+		paths:                paths,
+		excludeReferences:    excludeReferences,
+		generatedMode:        generatedMode,
+		countGenerated:       c.Generated.CountReferences == nil || *c.Generated.CountReferences,
+		directives:           newDirectives(),
+		generated:            newGeneratedFiles(),
+	}, nil
+}
+
+// toReferenceExclusions returns the default exclusions when none is
+// configured.
+func (c Config) toReferenceExclusions() ([]ReferenceExclusion, error) {
+	if c.ExcludeReferences == nil {
+		return defaultReferenceExclusions, nil
+	}
+	exclusions := make([]ReferenceExclusion, 0, len(c.ExcludeReferences))
+	for _, erc := range c.ExcludeReferences {
+		if erc.Name == "" {
+			return nil, fmt.Errorf("exclude-references entry without name")
+		}
+		if _, err := newPathFilter(erc.Paths, nil); err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, ReferenceExclusion{Name: erc.Name, Paths: erc.Paths})
+	}
+	return exclusions, nil
+}
+
+func (c Config) ToFailPolicy() (FailPolicy, error) {
+	kinds, err := parseSymbolKinds(c.FailOn.Kinds)
+	if err != nil {
+		return FailPolicy{}, err
+	}
+	policy := FailPolicy{
+		Enabled: c.FailOn.MaxUnused != nil || len(kinds) > 0,
+		Kinds:   kinds,
+	}
+	if c.FailOn.MaxUnused != nil {
+		policy.MaxUnused = *c.FailOn.MaxUnused
+	}
+	return policy, nil
+}
+
+func parseSymbolKinds(names []string) ([]lsp.SymbolKind, error) {
+	var kinds []lsp.SymbolKind
+	for _, symbolName := range names {
+		sk, err := lsp.ParseSymbolKind(symbolName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol kind '%s': %w", symbolName, err)
+		}
+		kinds = append(kinds, sk)
+	}
+	return kinds, nil
+}
+
+func (c Config) ToReachability() (Reachability, error) {
+	entrypoints := append([]Entrypoint{}, defaultEntrypoints...)
+	for _, ec := range c.Reachability.Entrypoints {
+		kinds, err := parseSymbolKinds(ec.Kinds)
+		if err != nil {
+			return Reachability{}, err
+		}
+		pathFilter, err := newPathFilter(ec.Paths, ec.ExcludePaths)
+		if err != nil {
+			return Reachability{}, err
+		}
+		entrypoints = append(entrypoints, Entrypoint{
+			Kinds:      kinds,
+			Names:      ec.Names,
+			PathFilter: pathFilter,
+		})
+	}
+	return Reachability{
+		Enabled:     c.Reachability.Enabled,
+		Exported:    c.Reachability.Exported == nil || *c.Reachability.Exported,
+		Entrypoints: entrypoints,
+	}, nil
+}
+
+func (c Config) ToTestOnlyPolicy() (TestOnlyPolicy, error) {
+	policy := TestOnlyPolicy{
+		Mode:     TestOnlyMode(c.TestOnly.Mode),
+		Severity: c.TestOnly.Severity,
+	}
+	if err := policy.validate(); err != nil {
+		return TestOnlyPolicy{}, err
+	}
+	return policy, nil
+}
+
+// ToLanguageServers returns the default language servers merged with the
+// configured ones, a configured server named like a default one overrides the
+// fields it sets.
+func (c Config) ToLanguageServers() ([]LanguageServer, error) {
+	servers := slices.Clone(defaultLanguageServers)
+	for _, lsc := range c.LanguageServers {
+		if lsc.Name == "" {
+			return nil, fmt.Errorf("language server without name")
+		}
+		ignoreSymbols, err := toIgnoreSymbols(lsc.IgnoreSymbols)
+		if err != nil {
+			return nil, fmt.Errorf("language server '%s': %w", lsc.Name, err)
+		}
+
+		i := slices.IndexFunc(servers, func(ls LanguageServer) bool { return ls.Name == lsc.Name })
+		if i < 0 {
+			servers = append(servers, LanguageServer{Name: lsc.Name, LanguageID: lsc.Name, OpenDocuments: true})
+			i = len(servers) - 1
+		}
+		ls := servers[i]
+		if lsc.Command != "" {
+			ls.Command = lsc.Command
+			ls.Args = nil
+		}
+		if lsc.Args != nil {
+			ls.Args = lsc.Args
+		}
+		if lsc.Extensions != nil {
+			ls.Extensions = lsc.Extensions
+		}
+		if lsc.LanguageID != "" {
+			ls.LanguageID = lsc.LanguageID
+		}
+		if lsc.InitializationOptions != nil {
+			ls.InitializationOptions = lsc.InitializationOptions
+		}
+		if lsc.OpenDocuments != nil {
+			ls.OpenDocuments = *lsc.OpenDocuments
+		}
+		ls.IgnoreSymbols = slices.Concat(ls.IgnoreSymbols, ignoreSymbols)
+
+		if ls.Command == "" || len(ls.Extensions) == 0 {
+			return nil, fmt.Errorf("language server '%s' requires a command and extensions", ls.Name)
+		}
+		servers[i] = ls
+	}
+	return servers, nil
+}
+
+// SelectLanguageServers returns the servers of the configured languages,
+// "auto" selects every registered server.
+func (c Config) SelectLanguageServers() ([]LanguageServer, error) {
+	servers, err := c.ToLanguageServers()
+	if err != nil {
+		return nil, err
+	}
+	languages := c.Languages
+	if c.Language != "" {
+		languages = append([]string{c.Language}, languages...)
+	}
+	if len(languages) == 0 {
+		languages = []string{DefaultLanguage}
+	}
+	if slices.Contains(languages, LanguageAuto) {
+		return servers, nil
+	}
+
+	var selected []LanguageServer
+	for _, language := range languages {
+		ls, err := LookupLanguageServer(servers, language)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(selected, func(s LanguageServer) bool { return s.Name == ls.Name }) {
+			selected = append(selected, ls)
+		}
+	}
+	return selected, nil
+}
+
+// ToClientOptions returns the language server client options, the "default"
+// timeout applies to every method without its own timeout.
+func (c Config) ToClientOptions() (ClientOptions, error) {
+	timeouts := Timeouts{Methods: make(map[string]time.Duration)}
+	for method, value := range c.Timeouts {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return ClientOptions{}, fmt.Errorf("invalid timeout for '%s': %w", method, err)
+		}
+		if method == "default" {
+			timeouts.Default = timeout
+			continue
+		}
+		timeouts.Methods[method] = timeout
+	}
+	if c.MaxInFlight < 0 {
+		return ClientOptions{}, fmt.Errorf("invalid max-in-flight %d", c.MaxInFlight)
+	}
+	return ClientOptions{
+		Timeouts:    timeouts,
+		MaxInFlight: c.MaxInFlight,
 	}, nil
 }
@@ -0,0 +1,187 @@
+package deadweight
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Diff holds the changes of a unified diff, lines are zero based and refer to
+// the new version of the files.
+type Diff struct {
+	Changed map[string][]LineRange
+	// RemovedIdentifiers are the identifiers found on removed lines.
+	RemovedIdentifiers map[string]bool
+}
+
+type LineRange struct {
+	Start int
+	End   int
+}
+
+func (lr LineRange) contains(line int) bool {
+	return line >= lr.Start && line <= lr.End
+}
+
+// GitDiff returns the changes between ref and the working tree of dir,
+// untracked files are considered fully changed.
+func GitDiff(ctx context.Context, dir string, ref string) (Diff, error) {
+	// explicit prefixes override diff.noprefix and diff.mnemonicPrefix
+	out, err := git(ctx, dir, "diff", "--relative", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "-U0", ref, "--")
+	if err != nil {
+		return Diff{}, err
+	}
+	diff, err := ParseUnifiedDiff(bytes.NewReader(out))
+	if err != nil {
+		return Diff{}, err
+	}
+
+	untracked, err := git(ctx, dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return Diff{}, err
+	}
+	for file := range strings.SplitSeq(strings.TrimSpace(string(untracked)), "\n") {
+		if file != "" {
+			diff.Changed[file] = []LineRange{{Start: 0, End: int(^uint(0) >> 1)}}
+		}
+	}
+	return diff, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func ParseUnifiedDiff(r io.Reader) (Diff, error) {
+	diff := Diff{
+		Changed:            make(map[string][]LineRange),
+		RemovedIdentifiers: make(map[string]bool),
+	}
+
+	var file string
+	inHunk := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			inHunk = false
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+		case !inHunk && strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "@@ "):
+			inHunk = true
+			if file == "" {
+				continue
+			}
+			lr, ok, err := parseHunkHeader(line)
+			if err != nil {
+				return Diff{}, err
+			}
+			if ok {
+				diff.Changed[file] = append(diff.Changed[file], lr)
+			}
+		case inHunk && strings.HasPrefix(line, "-"):
+			for _, identifier := range identifiers(line[1:]) {
+				diff.RemovedIdentifiers[identifier] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Diff{}, fmt.Errorf("failed to read diff: %w", err)
+	}
+	return diff, nil
+}
+
+// parseHunkHeader returns the new side range of a '@@ -a,b +c,d @@' header,
+// false when the hunk only removes lines.
+func parseHunkHeader(header string) (LineRange, bool, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return LineRange{}, false, fmt.Errorf("invalid hunk header '%s'", header)
+	}
+	start, count, found := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	first, err := strconv.Atoi(start)
+	if err != nil {
+		return LineRange{}, false, fmt.Errorf("invalid hunk header '%s': %w", header, err)
+	}
+	length := 1
+	if found {
+		if length, err = strconv.Atoi(count); err != nil {
+			return LineRange{}, false, fmt.Errorf("invalid hunk header '%s': %w", header, err)
+		}
+	}
+	if length == 0 {
+		return LineRange{}, false, nil
+	}
+	return LineRange{Start: first - 1, End: first - 1 + length - 1}, true, nil
+}
+
+// referencedName returns the identifier of a symbol name found at its
+// references, without the receiver of a method.
+func referencedName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func identifiers(line string) []string {
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// Relevant reports whether a symbol may have become unused because of the
+// change: it is declared on a changed line, or its name appears on a removed
+// line.
+func (d Diff) Relevant(filePath string, s Symbol) bool {
+	for _, lr := range d.Changed[filePath] {
+		if lr.contains(s.SelectionRange.Start.Line) {
+			return true
+		}
+	}
+	return d.RemovedIdentifiers[referencedName(s.Name)]
+}
+
+func (d Diff) Filter(findings []Finding) []Finding {
+	filtered := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		if d.Relevant(f.File, f.Symbol) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// FilterSymbols returns the relevant symbols, so that references are only
+// looked up for them.
+func (d Diff) FilterSymbols(symbols *SymbolMap) *SymbolMap {
+	filtered := NewSymbolMap()
+	for filePath, fileSymbols := range symbols.snapshot() {
+		for _, s := range fileSymbols {
+			if d.Relevant(filePath, s) {
+				filtered.Add(filePath, s)
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,134 @@
+package deadweight
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const multiFileDiff = `diff --git a/main.go b/main.go
+index 3b18e51..a5c1e2f 100644
+--- a/main.go
++++ b/main.go
+@@ -3 +3,2 @@ package main
+-func old() {}
++func added() {}
++func other() {}
+@@ -10,2 +11,0 @@ func main() {
+-	helper(1)
+-	legacyCall()
+@@ -20 +19 @@ func main() {
+-const a = 1
++const a = 2
+diff --git a/pkg/removed.go b/pkg/removed.go
+deleted file mode 100644
+index 9daeafb..0000000
+--- a/pkg/removed.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package pkg
+-
+-func Removed() {}
+diff --git a/pkg/new.go b/pkg/new.go
+new file mode 100644
+index 0000000..1f2a4f8
+--- /dev/null
++++ b/pkg/new.go
+@@ -0,0 +1,3 @@
++package pkg
++
++func New() {}
+`
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff, err := ParseUnifiedDiff(strings.NewReader(multiFileDiff))
+	if err != nil {
+		t.Fatalf("ParseUnifiedDiff() error = %v", err)
+	}
+
+	wantChanged := map[string][]LineRange{
+		"main.go":    {{Start: 2, End: 3}, {Start: 18, End: 18}},
+		"pkg/new.go": {{Start: 0, End: 2}},
+	}
+	if !maps.EqualFunc(diff.Changed, wantChanged, slices.Equal) {
+		t.Errorf("Changed = %v, want %v", diff.Changed, wantChanged)
+	}
+
+	for _, identifier := range []string{"old", "helper", "legacyCall", "a", "Removed", "pkg"} {
+		if !diff.RemovedIdentifiers[identifier] {
+			t.Errorf("RemovedIdentifiers misses %q", identifier)
+		}
+	}
+	for _, identifier := range []string{"added", "other", "New", "main"} {
+		if diff.RemovedIdentifiers[identifier] {
+			t.Errorf("RemovedIdentifiers contains %q", identifier)
+		}
+	}
+}
+
+func TestParseHunkHeader(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   LineRange
+		ok     bool
+	}{
+		{header: "@@ -3 +3,2 @@", want: LineRange{Start: 2, End: 3}, ok: true},
+		{header: "@@ -20 +19 @@ func main() {", want: LineRange{Start: 18, End: 18}, ok: true},
+		{header: "@@ -10,2 +11,0 @@", ok: false},
+		{header: "@@ -0,0 +1,3 @@", want: LineRange{Start: 0, End: 2}, ok: true},
+	} {
+		t.Run(tc.header, func(t *testing.T) {
+			got, ok, err := parseHunkHeader(tc.header)
+			if err != nil {
+				t.Fatalf("parseHunkHeader() error = %v", err)
+			}
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("parseHunkHeader() = %v, %v, want %v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+	if _, _, err := parseHunkHeader("@@ -1 @@"); err == nil {
+		t.Error("parseHunkHeader() accepted a header without new range")
+	}
+}
+
+func TestGitDiffPrefixes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, config := range []string{"diff.noprefix", "diff.mnemonicPrefix"} {
+		t.Run(config, func(t *testing.T) {
+			dir := t.TempDir()
+			run := func(args ...string) {
+				t.Helper()
+				if _, err := git(t.Context(), dir, args...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			run("init", "-q")
+			run("config", "user.email", "test@example.com")
+			run("config", "user.name", "test")
+			run("config", config, "true")
+			if err := os.WriteFile(filepath.Join(dir, "f.go"), []byte("package f\n\nfunc a() {}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			run("add", "f.go")
+			run("commit", "-q", "-m", "init")
+			if err := os.WriteFile(filepath.Join(dir, "f.go"), []byte("package f\n\nfunc b() {}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			diff, err := GitDiff(t.Context(), dir, "HEAD")
+			if err != nil {
+				t.Fatalf("GitDiff() error = %v", err)
+			}
+			if want := []LineRange{{Start: 2, End: 2}}; !slices.Equal(diff.Changed["f.go"], want) {
+				t.Errorf("Changed = %v, want f.go: %v", diff.Changed, want)
+			}
+		})
+	}
+}
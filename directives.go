@@ -0,0 +1,188 @@
+package deadweight
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	directiveIgnore     = "//deadweight:ignore"
+	directiveIgnoreFile = "//deadweight:ignore-file"
+)
+
+type directive struct {
+	line   int
+	reason string
+	isFile bool
+
+	suppressed []Symbol
+}
+
+type StaleDirective struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason,omitempty"`
+	IsFile bool   `json:"isFile,omitempty"`
+}
+
+type directives struct {
+	readFile func(string) ([]byte, error)
+	files    map[string][]*directive
+
+	sync.Mutex
+}
+
+func newDirectives() *directives {
+	return &directives{
+		readFile: os.ReadFile,
+		files:    make(map[string][]*directive),
+	}
+}
+
+func parseDirectives(content []byte) []*directive {
+	var result []*directive
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1024*1024)
+	for line := 0; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(text, "#"); ok {
+			text = "//" + rest
+		}
+		if rest, ok := strings.CutPrefix(text, directiveIgnoreFile); ok && (rest == "" || rest[0] == ' ') {
+			result = append(result, &directive{line: line, reason: strings.TrimSpace(rest), isFile: true})
+			continue
+		}
+		if rest, ok := strings.CutPrefix(text, directiveIgnore); ok && (rest == "" || rest[0] == ' ') {
+			result = append(result, &directive{line: line, reason: strings.TrimSpace(rest)})
+		}
+	}
+	return result
+}
+
+func (d *directives) fileDirectives(filePath string) []*directive {
+	fileDirectives, ok := d.files[filePath]
+	if ok {
+		return fileDirectives
+	}
+	content, err := d.readFile(filePath)
+	if err == nil {
+		fileDirectives = parseDirectives(content)
+	}
+	d.files[filePath] = fileDirectives
+	return fileDirectives
+}
+
+// suppresses reports whether a directive applies to the symbol, either an
+// ignore-file directive or an ignore directive on the line above it.
+func (d *directives) suppresses(filePath string, s Symbol) bool {
+	if d == nil {
+		return false
+	}
+	defer d.Unlock()
+	d.Lock()
+
+	for _, dir := range d.fileDirectives(filePath) {
+		if dir.isFile || dir.line+1 == s.Range.Start.Line || dir.line+1 == s.Position.Line {
+			dir.suppressed = append(dir.suppressed, s)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *directives) suppressedSymbols() *SymbolMap {
+	symbols := NewSymbolMap()
+	if d == nil {
+		return symbols
+	}
+	defer d.Unlock()
+	d.Lock()
+
+	for filePath, fileDirectives := range d.files {
+		for _, dir := range fileDirectives {
+			for _, s := range dir.suppressed {
+				symbols.Add(filePath, s)
+			}
+		}
+	}
+	return symbols
+}
+
+// stale returns the directives whose suppressed symbols are all used.
+func (d *directives) stale(references *ReferenceMap) []StaleDirective {
+	if d == nil {
+		return nil
+	}
+	defer d.Unlock()
+	d.Lock()
+	defer references.Unlock()
+	references.Lock()
+
+	var result []StaleDirective
+	for filePath, fileDirectives := range d.files {
+		for _, dir := range fileDirectives {
+			if slices.ContainsFunc(dir.suppressed, func(s Symbol) bool {
+				symbolReferences, ok := references.m[filePath][s]
+				return !ok || !references.counter.isUsed(symbolReferences, false)
+			}) {
+				continue
+			}
+			result = append(result, StaleDirective{
+				File:   filePath,
+				Line:   dir.line + 1,
+				Reason: dir.reason,
+				IsFile: dir.isFile,
+			})
+		}
+	}
+	slices.SortFunc(result, func(a, b StaleDirective) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	return result
+}
+
+// AddIgnoreDirectives inserts an ignore directive, with its reason, above the
+// declaration of each symbol. It returns the sorted lines of the original
+// content before which a line was inserted, once per inserted line.
+func AddIgnoreDirectives(content []byte, reasons map[Symbol]string) ([]byte, []int) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	directives := make(map[int][]string)
+	for s, reason := range reasons {
+		line := s.Range.Start.Line
+		if line < 0 || line >= len(lines) {
+			continue
+		}
+		indentation := string(lines[line][:len(lines[line])-len(bytes.TrimLeft(lines[line], " \t"))])
+		comment := cmp.Or(lineComments[s.Language], "//")
+		directive := indentation + comment + strings.TrimPrefix(directiveIgnore, "//")
+		if reason != "" {
+			directive += " " + reason
+		}
+
+		// gofmt separates directives from the text of doc comments
+		var separator []string
+		if s.Language == "go" && line > 0 {
+			previous := strings.TrimSpace(string(lines[line-1]))
+			if strings.HasPrefix(previous, "// ") {
+				separator = []string{indentation + "//\n"}
+			}
+		}
+		directives[line] = append(separator, directive+"\n")
+	}
+
+	var inserted []int
+	var result []byte
+	for line, text := range lines {
+		for _, directive := range directives[line] {
+			result = append(result, directive...)
+			inserted = append(inserted, line)
+		}
+		result = append(result, text...)
+	}
+	return result, inserted
+}
@@ -0,0 +1,26 @@
+package deadweight
+
+import (
+	"slices"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+type FailPolicy struct {
+	Enabled   bool
+	MaxUnused int
+	Kinds     []lsp.SymbolKind
+}
+
+func (p FailPolicy) Fails(findings []Finding) bool {
+	if !p.Enabled {
+		return false
+	}
+	count := 0
+	for _, f := range findings {
+		if len(p.Kinds) == 0 || slices.Contains(p.Kinds, f.Symbol.Kind) {
+			count++
+		}
+	}
+	return count > p.MaxUnused
+}
@@ -0,0 +1,61 @@
+package deadweight
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FileSource lists the files to analyze, as slash separated paths relative
+// to the root.
+type FileSource interface {
+	Files(root string, servers []LanguageServer, rules Rules) ([]string, error)
+}
+
+// WalkFiles lists the files of the root directory handled by one of the
+// servers. Hidden and vendor directories, test files unless Tests is set and
+// the paths excluded by the rules are skipped.
+type WalkFiles struct {
+	Tests bool
+}
+
+func (wf WalkFiles) Files(root string, servers []LanguageServer, rules Rules) ([]string, error) {
+	var sourceFiles []string
+	if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+		if d.IsDir() {
+			name := d.Name()
+			if path == root {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || name == "vendor" || !rules.KeepDir(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if slices.ContainsFunc(servers, func(ls LanguageServer) bool { return ls.HandlesFile(path) }) {
+			if (wf.Tests || !isTestFile(path)) && rules.KeepFile(relPath) {
+				sourceFiles = append(sourceFiles, relPath)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	return sourceFiles, nil
+}
+
+// FileList analyzes a fixed list of files.
+type FileList []string
+
+func (fl FileList) Files(string, []LanguageServer, Rules) ([]string, error) {
+	return fl, nil
+}
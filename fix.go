@@ -0,0 +1,238 @@
+package deadweight
+
+import (
+	"bytes"
+	"cmp"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+// lineComments are the line comment prefixes of the languages that do not use
+// '//'.
+var lineComments = map[string]string{
+	"python": "#",
+}
+
+// RemoveDeclarations deletes the declarations of the unused symbols from the
+// content of a file, along with their doc comments. symbols are all the
+// symbols of the file: a declaration containing a symbol that is not removed
+// is skipped, as well as a declaration that does not span whole lines or a Go
+// constant whose removal would change the value of the others of its group.
+func RemoveDeclarations(content []byte, unused []Symbol, symbols []Symbol) ([]byte, []Symbol, []Symbol) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	removedLines := make([]bool, len(lines))
+
+	var pinned func(line int) bool
+	var removed, skipped []Symbol
+	for _, s := range unused {
+		start, end, ok := declarationLines(lines, s)
+		if ok && s.Language == "go" && s.Kind == lsp.SymbolKindConstant {
+			if pinned == nil {
+				pinned = pinnedConstants(content)
+			}
+			ok = !pinned(s.SelectionRange.Start.Line)
+		}
+		if ok {
+			ok = !slices.ContainsFunc(symbols, func(other Symbol) bool {
+				line := other.SelectionRange.Start.Line
+				return line >= start && line <= end && !slices.Contains(unused, other)
+			})
+		}
+		if !ok {
+			skipped = append(skipped, s)
+			continue
+		}
+		for line := start; line <= end; line++ {
+			removedLines[line] = true
+		}
+		removed = append(removed, s)
+	}
+	if len(removed) == 0 {
+		return content, nil, skipped
+	}
+
+	// the blank lines between two removed declarations go with them
+	for line := range lines {
+		if !removedLines[line] || line+1 >= len(lines) || !isBlank(lines[line+1]) {
+			continue
+		}
+		next := line + 1
+		for next < len(lines) && isBlank(lines[next]) && !removedLines[next] {
+			next++
+		}
+		if next < len(lines) && removedLines[next] {
+			for blank := line + 1; blank < next; blank++ {
+				removedLines[blank] = true
+			}
+		}
+	}
+
+	// avoid leaving two blank lines, or a blank line at the edge of a block,
+	// looking at the kept lines around each removed run
+	var blanks []int
+	for start := 0; start < len(lines); start++ {
+		if !removedLines[start] {
+			continue
+		}
+		end := start
+		for end+1 < len(lines) && removedLines[end+1] {
+			end++
+		}
+		previous, next := start-1, end+1
+		switch {
+		case next >= len(lines) || len(lines[next]) == 0 || closesBlock(lines[next]):
+			if previous >= 0 && isBlank(lines[previous]) {
+				blanks = append(blanks, previous)
+			}
+		case isBlank(lines[next]):
+			if previous < 0 || isBlank(lines[previous]) || opensBlock(lines[previous]) {
+				blanks = append(blanks, next)
+			}
+		}
+		start = end
+	}
+	for _, line := range blanks {
+		removedLines[line] = true
+	}
+
+	var result []byte
+	for line, text := range lines {
+		if !removedLines[line] {
+			result = append(result, text...)
+		}
+	}
+	return result, removed, skipped
+}
+
+// declarationLines returns the lines covered by the declaration of a symbol
+// and its doc comment.
+func declarationLines(lines [][]byte, s Symbol) (int, int, bool) {
+	switch s.Kind {
+	case lsp.SymbolKindFile, lsp.SymbolKindModule, lsp.SymbolKindNamespace, lsp.SymbolKindPackage:
+		return 0, 0, false
+	}
+	start, end := s.Range.Start.Line, s.Range.End.Line
+	if start < 0 || end >= len(lines) || end < start {
+		return 0, 0, false
+	}
+
+	// only declaration keywords like 'const' or 'var' can precede it
+	prefix := lines[start][:byteOffset(lines[start], s.Range.Start.Character)]
+	if strings.ContainsFunc(string(prefix), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsSpace(r)
+	}) {
+		return 0, 0, false
+	}
+	suffix := strings.TrimSpace(string(lines[end][byteOffset(lines[end], s.Range.End.Character):]))
+	suffix = strings.TrimSpace(strings.TrimLeft(suffix, ",;"))
+	comment := cmp.Or(lineComments[s.Language], "//")
+	if suffix != "" && !strings.HasPrefix(suffix, comment) {
+		return 0, 0, false
+	}
+
+	for start > 0 {
+		previous := strings.TrimSpace(string(lines[start-1]))
+		if strings.HasPrefix(previous, comment) {
+			start--
+			continue
+		}
+		if !strings.HasSuffix(previous, "*/") {
+			break
+		}
+		opening := start - 1
+		for opening >= 0 && !strings.Contains(string(lines[opening]), "/*") {
+			opening--
+		}
+		if opening < 0 || !strings.HasPrefix(strings.TrimSpace(string(lines[opening])), "/*") {
+			break
+		}
+		start = opening
+	}
+	return start, end, true
+}
+
+// pinnedConstants reports whether the Go constant declared on a line cannot be
+// removed from its group: its value is implicit or uses iota, the next constant
+// repeats it, or a later one uses iota. All of them are pinned when the content
+// does not parse.
+func pinnedConstants(content []byte) func(line int) bool {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if err != nil {
+		return func(int) bool { return true }
+	}
+	pinned := make(map[int]bool)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST || !gen.Lparen.IsValid() {
+			continue
+		}
+		for i, spec := range gen.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			pin := len(valueSpec.Values) == 0 || usesIota(valueSpec)
+			if i+1 < len(gen.Specs) && len(gen.Specs[i+1].(*ast.ValueSpec).Values) == 0 {
+				pin = true
+			}
+			for _, later := range gen.Specs[i+1:] {
+				pin = pin || usesIota(later.(*ast.ValueSpec))
+			}
+			if !pin {
+				continue
+			}
+			for _, name := range valueSpec.Names {
+				pinned[fset.Position(name.Pos()).Line-1] = true
+			}
+		}
+	}
+	return func(line int) bool { return pinned[line] }
+}
+
+func usesIota(spec *ast.ValueSpec) bool {
+	found := false
+	for _, value := range spec.Values {
+		ast.Inspect(value, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Name == "iota" {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// byteOffset converts a character offset, counted in UTF-16 code units, to a
+// byte offset in the line.
+func byteOffset(line []byte, character int) int {
+	offset, units := 0, 0
+	for offset < len(line) && units < character {
+		r, size := utf8.DecodeRune(line[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+func isBlank(line []byte) bool {
+	return len(bytes.TrimSpace(line)) == 0
+}
+
+func opensBlock(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	return bytes.HasSuffix(trimmed, []byte("{")) || bytes.HasSuffix(trimmed, []byte("("))
+}
+
+func closesBlock(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	return bytes.HasPrefix(trimmed, []byte("}")) || bytes.HasPrefix(trimmed, []byte(")"))
+}
@@ -0,0 +1,187 @@
+package deadweight
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+// goSymbol returns a Go symbol whose declaration spans from start to end, and
+// whose name starts at selection.
+func goSymbol(name string, kind lsp.SymbolKind, start, end, selection lsp.Position) Symbol {
+	return Symbol{
+		Name:     name,
+		Kind:     kind,
+		Language: "go",
+		Range:    lsp.Range{Start: start, End: end},
+		SelectionRange: lsp.Range{
+			Start: selection,
+			End:   lsp.Position{Line: selection.Line, Character: selection.Character + len(name)},
+		},
+	}
+}
+
+func TestRemoveDeclarations(t *testing.T) {
+	red := goSymbol("Red", lsp.SymbolKindConstant, lsp.Position{Line: 3, Character: 1}, lsp.Position{Line: 3, Character: 11}, lsp.Position{Line: 3, Character: 1})
+	green := goSymbol("Green", lsp.SymbolKindConstant, lsp.Position{Line: 4, Character: 1}, lsp.Position{Line: 4, Character: 6}, lsp.Position{Line: 4, Character: 1})
+	blue := goSymbol("Blue", lsp.SymbolKindConstant, lsp.Position{Line: 5, Character: 1}, lsp.Position{Line: 5, Character: 5}, lsp.Position{Line: 5, Character: 1})
+
+	first := goSymbol("A", lsp.SymbolKindConstant, lsp.Position{Line: 3, Character: 1}, lsp.Position{Line: 3, Character: 6}, lsp.Position{Line: 3, Character: 1})
+	second := goSymbol("B", lsp.SymbolKindConstant, lsp.Position{Line: 4, Character: 1}, lsp.Position{Line: 4, Character: 6}, lsp.Position{Line: 4, Character: 1})
+	implicit := goSymbol("B", lsp.SymbolKindConstant, lsp.Position{Line: 4, Character: 1}, lsp.Position{Line: 4, Character: 2}, lsp.Position{Line: 4, Character: 1})
+	withIota := goSymbol("B", lsp.SymbolKindConstant, lsp.Position{Line: 4, Character: 1}, lsp.Position{Line: 4, Character: 9}, lsp.Position{Line: 4, Character: 1})
+
+	a := goSymbol("a", lsp.SymbolKindVariable, lsp.Position{Line: 2, Character: 4}, lsp.Position{Line: 2, Character: 15}, lsp.Position{Line: 2, Character: 4})
+	b := goSymbol("b", lsp.SymbolKindVariable, lsp.Position{Line: 2, Character: 4}, lsp.Position{Line: 2, Character: 15}, lsp.Position{Line: 2, Character: 7})
+
+	lineDoc := goSymbol("Foo", lsp.SymbolKindFunction, lsp.Position{Line: 3, Character: 0}, lsp.Position{Line: 3, Character: 13}, lsp.Position{Line: 3, Character: 5})
+	lineDocBar := goSymbol("Bar", lsp.SymbolKindFunction, lsp.Position{Line: 5, Character: 0}, lsp.Position{Line: 5, Character: 13}, lsp.Position{Line: 5, Character: 5})
+	blockDoc := goSymbol("Foo", lsp.SymbolKindFunction, lsp.Position{Line: 5, Character: 0}, lsp.Position{Line: 5, Character: 13}, lsp.Position{Line: 5, Character: 5})
+	blockDocBar := goSymbol("Bar", lsp.SymbolKindFunction, lsp.Position{Line: 7, Character: 0}, lsp.Position{Line: 7, Character: 13}, lsp.Position{Line: 7, Character: 5})
+
+	perimeter := goSymbol("Perimeter", lsp.SymbolKindFunction, lsp.Position{Line: 4, Character: 0}, lsp.Position{Line: 4, Character: 19}, lsp.Position{Line: 4, Character: 5})
+	unusedInterface := goSymbol("Unused", lsp.SymbolKindInterface, lsp.Position{Line: 6, Character: 5}, lsp.Position{Line: 6, Character: 23}, lsp.Position{Line: 6, Character: 5})
+	kept := goSymbol("A", lsp.SymbolKindFunction, lsp.Position{Line: 2, Character: 0}, lsp.Position{Line: 2, Character: 11}, lsp.Position{Line: 2, Character: 5})
+	keptAfter := goSymbol("B", lsp.SymbolKindFunction, lsp.Position{Line: 8, Character: 0}, lsp.Position{Line: 8, Character: 11}, lsp.Position{Line: 8, Character: 5})
+
+	trailing := goSymbol("X", lsp.SymbolKindConstant, lsp.Position{Line: 2, Character: 6}, lsp.Position{Line: 2, Character: 11}, lsp.Position{Line: 2, Character: 6})
+	shared := goSymbol("x", lsp.SymbolKindVariable, lsp.Position{Line: 2, Character: 4}, lsp.Position{Line: 2, Character: 9}, lsp.Position{Line: 2, Character: 4})
+	sharedY := goSymbol("y", lsp.SymbolKindVariable, lsp.Position{Line: 2, Character: 15}, lsp.Position{Line: 2, Character: 20}, lsp.Position{Line: 2, Character: 15})
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		unused  []Symbol
+		symbols []Symbol
+		want    string
+		removed []Symbol
+		skipped []Symbol
+	}{
+		{
+			name:    "implicit iota value",
+			content: "package p\n\nconst (\n\tRed = iota\n\tGreen\n\tBlue\n)\n",
+			unused:  []Symbol{green},
+			symbols: []Symbol{red, green, blue},
+			want:    "package p\n\nconst (\n\tRed = iota\n\tGreen\n\tBlue\n)\n",
+			skipped: []Symbol{green},
+		},
+		{
+			name:    "first iota value",
+			content: "package p\n\nconst (\n\tRed = iota\n\tGreen\n\tBlue\n)\n",
+			unused:  []Symbol{red},
+			symbols: []Symbol{red, green, blue},
+			want:    "package p\n\nconst (\n\tRed = iota\n\tGreen\n\tBlue\n)\n",
+			skipped: []Symbol{red},
+		},
+		{
+			name:    "value repeated by the next constant",
+			content: "package p\n\nconst (\n\tA = 1\n\tB\n)\n",
+			unused:  []Symbol{first},
+			symbols: []Symbol{first, implicit},
+			want:    "package p\n\nconst (\n\tA = 1\n\tB\n)\n",
+			skipped: []Symbol{first},
+		},
+		{
+			name:    "followed by iota",
+			content: "package p\n\nconst (\n\tA = 1\n\tB = iota\n)\n",
+			unused:  []Symbol{first},
+			symbols: []Symbol{first, withIota},
+			want:    "package p\n\nconst (\n\tA = 1\n\tB = iota\n)\n",
+			skipped: []Symbol{first},
+		},
+		{
+			name:    "last spec of a block",
+			content: "package p\n\nconst (\n\tA = 1\n\tB = 2\n)\n",
+			unused:  []Symbol{second},
+			symbols: []Symbol{first, second},
+			want:    "package p\n\nconst (\n\tA = 1\n)\n",
+			removed: []Symbol{second},
+		},
+		{
+			name:    "first spec of a block",
+			content: "package p\n\nconst (\n\tA = 1\n\tB = 2\n)\n",
+			unused:  []Symbol{first},
+			symbols: []Symbol{first, second},
+			want:    "package p\n\nconst (\n\tB = 2\n)\n",
+			removed: []Symbol{first},
+		},
+		{
+			name:    "multi-name spec partly used",
+			content: "package p\n\nvar a, b = 1, 2\n",
+			unused:  []Symbol{a},
+			symbols: []Symbol{a, b},
+			want:    "package p\n\nvar a, b = 1, 2\n",
+			skipped: []Symbol{a},
+		},
+		{
+			name:    "multi-name spec unused",
+			content: "package p\n\nvar a, b = 1, 2\n",
+			unused:  []Symbol{a, b},
+			symbols: []Symbol{a, b},
+			want:    "package p\n",
+			removed: []Symbol{a, b},
+		},
+		{
+			name:    "doc comment",
+			content: "package p\n\n// Foo does nothing.\nfunc Foo() {}\n\nfunc Bar() {}\n",
+			unused:  []Symbol{lineDoc},
+			symbols: []Symbol{lineDoc, lineDocBar},
+			want:    "package p\n\nfunc Bar() {}\n",
+			removed: []Symbol{lineDoc},
+		},
+		{
+			name:    "block comment",
+			content: "package p\n\n/*\nFoo does nothing.\n*/\nfunc Foo() {}\n\nfunc Bar() {}\n",
+			unused:  []Symbol{blockDoc},
+			symbols: []Symbol{blockDoc, blockDocBar},
+			want:    "package p\n\nfunc Bar() {}\n",
+			removed: []Symbol{blockDoc},
+		},
+		{
+			name:    "adjacent declarations",
+			content: "package p\n\nfunc A() {}\n\nfunc Perimeter() {}\n\ntype Unused interface{}\n\nfunc B() {}\n",
+			unused:  []Symbol{perimeter, unusedInterface},
+			symbols: []Symbol{kept, perimeter, unusedInterface, keptAfter},
+			want:    "package p\n\nfunc A() {}\n\nfunc B() {}\n",
+			removed: []Symbol{perimeter, unusedInterface},
+		},
+		{
+			name:    "adjacent declarations at the end",
+			content: "package p\n\nfunc A() {}\n\nfunc Perimeter() {}\n\ntype Unused interface{}\n",
+			unused:  []Symbol{perimeter, unusedInterface},
+			symbols: []Symbol{kept, perimeter, unusedInterface},
+			want:    "package p\n\nfunc A() {}\n",
+			removed: []Symbol{perimeter, unusedInterface},
+		},
+		{
+			name:    "trailing comment",
+			content: "package p\n\nconst X = 1 // unused\n",
+			unused:  []Symbol{trailing},
+			symbols: []Symbol{trailing},
+			want:    "package p\n",
+			removed: []Symbol{trailing},
+		},
+		{
+			name:    "declaration sharing its line",
+			content: "package p\n\nvar x = 1; var y = 2\n",
+			unused:  []Symbol{shared},
+			symbols: []Symbol{shared, sharedY},
+			want:    "package p\n\nvar x = 1; var y = 2\n",
+			skipped: []Symbol{shared},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, removed, skipped := RemoveDeclarations([]byte(tc.content), tc.unused, tc.symbols)
+			if string(got) != tc.want {
+				t.Errorf("content = %q, want %q", got, tc.want)
+			}
+			if !slices.Equal(removed, tc.removed) {
+				t.Errorf("removed = %v, want %v", removed, tc.removed)
+			}
+			if !slices.Equal(skipped, tc.skipped) {
+				t.Errorf("skipped = %v, want %v", skipped, tc.skipped)
+			}
+		})
+	}
+}
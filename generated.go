@@ -0,0 +1,91 @@
+package deadweight
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// GeneratedMode tells how the symbols of generated files are reported.
+type GeneratedMode string
+
+const (
+	// GeneratedSkip does not scan generated files for symbols.
+	GeneratedSkip GeneratedMode = "skip"
+	// GeneratedReport reports them like the symbols of any other file.
+	GeneratedReport GeneratedMode = "report"
+	// GeneratedSeparate reports them in their own category of the report.
+	GeneratedSeparate GeneratedMode = "separate"
+)
+
+// excludedGenerated names the exclusion discounting references from generated
+// files.
+const excludedGenerated = "generated"
+
+// generatedHeader is the comment marking generated files, see
+// https://go.dev/s/generatedcode.
+var generatedHeader = regexp.MustCompile(`^(//|#|--|/?\*+)\s*Code generated .* DO NOT EDIT\.`)
+
+func parseGeneratedMode(mode string) (GeneratedMode, error) {
+	if mode == "" {
+		return GeneratedReport, nil
+	}
+	if !slices.Contains([]GeneratedMode{GeneratedSkip, GeneratedReport, GeneratedSeparate}, GeneratedMode(mode)) {
+		return "", fmt.Errorf("unknown generated mode %q", mode)
+	}
+	return GeneratedMode(mode), nil
+}
+
+// generatedFiles remembers which files are generated.
+type generatedFiles struct {
+	readFile func(string) ([]byte, error)
+	files    map[string]bool
+
+	sync.Mutex
+}
+
+func newGeneratedFiles() *generatedFiles {
+	return &generatedFiles{
+		readFile: os.ReadFile,
+		files:    make(map[string]bool),
+	}
+}
+
+// isGenerated reports whether the header of the file, the comments and blank
+// lines before any code, holds the generated code marker.
+func isGenerated(content []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if generatedHeader.MatchString(line) {
+			return true
+		}
+		if line != "" && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "#") &&
+			!strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "/*") && !strings.HasPrefix(line, "*") {
+			return false
+		}
+	}
+	return false
+}
+
+func (g *generatedFiles) is(filePath string) bool {
+	if g == nil {
+		return false
+	}
+	defer g.Unlock()
+	g.Lock()
+
+	generated, ok := g.files[filePath]
+	if !ok {
+		content, err := g.readFile(filePath)
+		generated = err == nil && isGenerated(content)
+		g.files[filePath] = generated
+	}
+	return generated
+}
@@ -2,4 +2,7 @@ module github.com/theo303/deadweight
 
 go 1.25.4
 
-require github.com/goccy/go-yaml v1.19.2
+require (
+	github.com/bmatcuk/doublestar/v4 v4.10.0
+	github.com/goccy/go-yaml v1.19.2
+)
@@ -0,0 +1,119 @@
+package deadweight
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// goPackages is the import graph of the Go packages of a workspace, keyed by
+// their slash separated directory relative to the root.
+type goPackages struct {
+	// modules are the module paths declared by the go.mod files.
+	modules map[string]string
+	imports map[string][]string
+}
+
+// newGoPackages prepares the graph of root, whose module may be declared by a
+// go.mod file above it.
+func newGoPackages(root string) *goPackages {
+	gp := &goPackages{
+		modules: make(map[string]string),
+		imports: make(map[string][]string),
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return gp
+	}
+	for dir := absRoot; ; dir = filepath.Dir(dir) {
+		if content, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			if module := modulePath(content); module != "" {
+				rel, _ := filepath.Rel(dir, absRoot)
+				gp.modules["."] = path.Join(module, filepath.ToSlash(rel))
+			}
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return gp
+}
+
+func modulePath(content []byte) string {
+	for line := range strings.Lines(string(content)) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			if module, err := strconv.Unquote(fields[1]); err == nil {
+				return module
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// add records the go.mod file or the imports of the Go file.
+func (gp *goPackages) add(file string, content []byte) {
+	dir := path.Dir(file)
+	if path.Base(file) == "go.mod" {
+		if module := modulePath(content); module != "" {
+			gp.modules[dir] = module
+		}
+		return
+	}
+	if path.Ext(file) != ".go" {
+		return
+	}
+	var imports []string
+	if f, _ := parser.ParseFile(token.NewFileSet(), "", content, parser.ImportsOnly); f != nil {
+		for _, spec := range f.Imports {
+			if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports = append(imports, importPath)
+			}
+		}
+	}
+	// the directory is recorded even without imports
+	gp.imports[dir] = append(gp.imports[dir], imports...)
+}
+
+// importPath returns the import path of the package of a directory, false
+// when no module declares it.
+func (gp *goPackages) importPath(dir string) (string, bool) {
+	for moduleDir := dir; ; moduleDir = path.Dir(moduleDir) {
+		if module, ok := gp.modules[moduleDir]; ok {
+			if moduleDir == "." {
+				return path.Join(module, dir), true
+			}
+			return path.Join(module, strings.TrimPrefix(dir, moduleDir)), true
+		}
+		if moduleDir == "." {
+			return "", false
+		}
+	}
+}
+
+// importers returns, for every directory, the directories directly importing
+// its package.
+func (gp *goPackages) importers() map[string][]string {
+	dirs := make(map[string]string)
+	for dir := range gp.imports {
+		if importPath, ok := gp.importPath(dir); ok {
+			dirs[importPath] = dir
+		}
+	}
+	importers := make(map[string][]string)
+	for dir, imports := range gp.imports {
+		for _, importPath := range imports {
+			if imported, ok := dirs[importPath]; ok && imported != dir && !slices.Contains(importers[imported], dir) {
+				importers[imported] = append(importers[imported], dir)
+			}
+		}
+	}
+	return importers
+}
@@ -1,6 +1,7 @@
 package deadweight
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"sync"
@@ -10,72 +11,144 @@ import (
 
 type messageHandler func(lsp.Message)
 
-func initializeResponse(ready chan struct{}) messageHandler {
+func initializeResponse(ready chan error) messageHandler {
 	return func(m lsp.Message) {
-		ready <- struct{}{}
+		if m.Error != nil {
+			ready <- m.Error
+			return
+		}
+		ready <- nil
 	}
 }
 
-func getAllSymbols(documentSymbol lsp.DocumentSymbol) []lsp.DocumentSymbol {
-	children := make([]lsp.DocumentSymbol, 0, len(documentSymbol.Children))
-	children = append(children, documentSymbol)
+type containedSymbol struct {
+	lsp.DocumentSymbol
+	container string
+}
+
+func getAllSymbols(documentSymbol lsp.DocumentSymbol, container string) []containedSymbol {
+	children := make([]containedSymbol, 0, len(documentSymbol.Children)+1)
+	children = append(children, containedSymbol{DocumentSymbol: documentSymbol, container: container})
 	for _, child := range documentSymbol.Children {
-		children = append(children, getAllSymbols(child)...)
+		children = append(children, getAllSymbols(child, documentSymbol.Name)...)
 	}
 	return children
 }
 
-func (lc *lspClient) documentSymbolResponse(wg *sync.WaitGroup, symbols *SymbolMap, filePath string) messageHandler {
+func (lc *lspClient) documentSymbolResponse(ctx context.Context, wg *sync.WaitGroup, symbols *SymbolMap, filePath string) messageHandler {
 	return func(m lsp.Message) {
 		defer wg.Done()
+		if m.Error != nil {
+			lc.logger.Error("document symbol request failed", slog.String("filePath", filePath), slog.Any("error", m.Error))
+			return
+		}
 		var results []lsp.DocumentSymbol
 		if err := json.Unmarshal(m.Result, &results); err != nil {
-			slog.Error("document symbol response unmarshal error", slog.Any("error", err))
+			lc.logger.Error("document symbol response unmarshal error", slog.Any("error", err))
 			return
 		}
 		if len(results) == 0 {
 			return
 		}
 		var fileSymbols []Symbol
-		var err error
+		cacheable := true
 		for _, result := range results {
-			for _, symbol := range getAllSymbols(result) {
-				s := NewSymbol(symbol)
-				s.IsEmbeddedField, err = lc.isEmbedded(filePath, symbol)
+			for _, symbol := range getAllSymbols(result, "") {
+				s := NewSymbol(symbol.DocumentSymbol, symbol.container)
+				s.Language = lc.server.Name
+				var err error
+				s.IsEmbeddedField, err = lc.isEmbedded(ctx, filePath, symbol.DocumentSymbol)
 				if err != nil {
-					slog.Error("isEmbedded error, skipping symbol", slog.Any("error", err),
+					lc.logger.Debug("isEmbedded error, reporting symbol as unresolved", slog.Any("error", err),
 						slog.String("filePath", filePath),
 						slog.String("symbolName", symbol.Name),
 						slog.Int("symbolLine", symbol.SelectionRange.Start.Line),
 						slog.Int("symbolCharacter", symbol.SelectionRange.Start.Character),
 					)
+					cacheable = false
+					if lc.rules.Load().KeepSymbol(filePath, s) {
+						symbols.StoreError(filePath, s, err)
+					}
 					continue
 				}
-				if lc.rules.KeepSymbol(filePath, s) {
-					fileSymbols = append(fileSymbols, s)
-				}
+				fileSymbols = append(fileSymbols, s)
 			}
 		}
-		symbols.Store(filePath, fileSymbols)
+		if cacheable {
+			lc.cache.Load().PutSymbols(lc.server, filePath, fileSymbols)
+		}
+		lc.storeSymbols(symbols, filePath, fileSymbols)
 	}
 }
 
-func referencesResponse(wg *sync.WaitGroup, references *ReferenceMap, filePath string, symbol Symbol) messageHandler {
+// storeSymbols stores the symbols of a file kept by the rules.
+func (lc *lspClient) storeSymbols(symbols *SymbolMap, filePath string, fileSymbols []Symbol) {
+	rules := lc.rules.Load()
+	var kept []Symbol
+	for _, s := range fileSymbols {
+		if rules.KeepSymbol(filePath, s) {
+			kept = append(kept, s)
+		}
+	}
+	symbols.Store(filePath, kept)
+}
+
+func (lc *lspClient) referencesResponse(wg *sync.WaitGroup, references *ReferenceMap, filePath string, symbol Symbol) messageHandler {
 	return func(m lsp.Message) {
 		defer wg.Done()
 
+		if m.Error != nil {
+			lc.logger.Debug("references request failed", slog.String("filePath", filePath), slog.String("symbolName", symbol.Name), slog.Any("error", m.Error))
+			references.StoreError(filePath, symbol, m.Error)
+			return
+		}
+
 		var symbolReferences []lsp.Location
 		if err := json.Unmarshal(m.Result, &symbolReferences); err != nil {
-			slog.Error("references response unmarshal error", slog.Any("error", err))
+			lc.logger.Error("references response unmarshal error", slog.Any("error", err))
+			references.StoreError(filePath, symbol, err)
 			return
 		}
 
+		lc.cache.Load().PutReferences(lc.server, filePath, symbol, symbolReferences)
 		references.Store(filePath, symbol, symbolReferences)
 	}
 }
 
-func positionHasSymbolResponse(hasSymbol chan bool) messageHandler {
+func (lc *lspClient) implementationResponse(wg *sync.WaitGroup, references *ReferenceMap, filePath string, symbol Symbol) messageHandler {
 	return func(m lsp.Message) {
-		hasSymbol <- len(m.Result) != 0
+		defer wg.Done()
+
+		if m.Error != nil {
+			// the method is then only used through its references
+			lc.logger.Debug("implementation request failed", slog.String("filePath", filePath), slog.String("symbolName", symbol.Name), slog.Any("error", m.Error))
+			return
+		}
+
+		var implementations []lsp.Location
+		if err := json.Unmarshal(m.Result, &implementations); err != nil {
+			lc.logger.Error("implementation response unmarshal error", slog.Any("error", err))
+			return
+		}
+		references.StoreImplementations(filePath, symbol, implementations)
+	}
+}
+
+// positionSymbol tells whether a symbol was found at a position, err is set
+// when the request did not get an answer from the server.
+type positionSymbol struct {
+	found bool
+	err   error
+}
+
+func positionHasSymbolResponse(hasSymbol chan positionSymbol) messageHandler {
+	return func(m lsp.Message) {
+		if m.Error != nil && (m.Error.Code == lsp.ErrorCodeRequestCancelled || m.Error.Code == lsp.ErrorCodeRequestFailed || m.Error.Retryable()) {
+			hasSymbol <- positionSymbol{err: m.Error}
+			return
+		}
+		// an error from the server means there is nothing to resolve at this
+		// position
+		hasSymbol <- positionSymbol{found: m.Error == nil && len(m.Result) != 0}
 	}
 }
@@ -1,15 +1,45 @@
 package deadweight
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
 	"slices"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/theo303/deadweight/lsp"
 )
 
 type Rules struct {
 	ignoreSymbols        []IgnoreSymbols
 	ignoreEmbeddedFields bool
+	paths                PathFilter
+	excludeReferences    []ReferenceExclusion
+	generatedMode        GeneratedMode
+	// countGenerated makes references from generated files count as uses.
+	countGenerated bool
+
+	directives *directives
+	generated  *generatedFiles
+}
+
+// KeepFile reports whether a file is scanned for symbols.
+func (r Rules) KeepFile(filePath string) bool {
+	if r.generatedMode == GeneratedSkip && r.generated.is(filePath) {
+		return false
+	}
+	return r.paths.Match(filePath) && !r.excludesReferences(filePath)
+}
+
+// KeepDir reports whether a directory is walked to look for files.
+func (r Rules) KeepDir(dirPath string) bool {
+	return !r.paths.excluded(dirPath) && !r.excludesReferences(dirPath)
+}
+
+func (r Rules) excludesReferences(filePath string) bool {
+	return slices.ContainsFunc(r.excludeReferences, func(e ReferenceExclusion) bool {
+		return matchPath(e.Paths, filePath)
+	})
 }
 
 func (r Rules) KeepSymbol(filePath string, s Symbol) bool {
@@ -21,22 +51,98 @@ func (r Rules) KeepSymbol(filePath string, s Symbol) bool {
 			return false
 		}
 	}
-	return true
+	return !r.directives.suppresses(filePath, s)
+}
+
+// WithOverlay returns rules reading directives and generated file headers
+// from the overlay first, the content of the other files is read from the
+// disk, relative to root.
+func (r Rules) WithOverlay(root string, overlay map[string][]byte) Rules {
+	readFile := func(filePath string) ([]byte, error) {
+		if content, ok := overlay[filePath]; ok {
+			return content, nil
+		}
+		return os.ReadFile(filepath.Join(root, filePath))
+	}
+	r.directives = newDirectives()
+	r.directives.readFile = readFile
+	if r.generated != nil {
+		r.generated = newGeneratedFiles()
+		r.generated.readFile = readFile
+	}
+	return r
+}
+
+// SuppressedSymbols returns the symbols skipped because of a
+// //deadweight:ignore directive.
+func (r Rules) SuppressedSymbols() *SymbolMap {
+	return r.directives.suppressedSymbols()
+}
+
+// StaleDirectives returns the directives that no longer suppress anything,
+// references must contain the references of the suppressed symbols.
+func (r Rules) StaleDirectives(references *ReferenceMap) []StaleDirective {
+	return r.directives.stale(references)
 }
 
 type IgnoreSymbols struct {
 	Kinds []lsp.SymbolKind
 	Names []string
+	PathFilter
+}
+
+func (ir IgnoreSymbols) ignore(filePath string, s Symbol) bool {
+	return ir.Match(filePath) && matchSymbol(ir.Kinds, ir.Names, s)
+}
+
+// PathFilter matches slash separated paths, relative to the analyzed
+// directory, against doublestar glob patterns. Empty Paths match every path.
+type PathFilter struct {
+	Paths        []string
+	ExcludePaths []string
+}
+
+func newPathFilter(paths, excludePaths []string) (PathFilter, error) {
+	for _, pattern := range slices.Concat(paths, excludePaths) {
+		if !doublestar.ValidatePattern(pattern) {
+			return PathFilter{}, fmt.Errorf("invalid path pattern '%s'", pattern)
+		}
+	}
+	return PathFilter{
+		Paths:        paths,
+		ExcludePaths: excludePaths,
+	}, nil
+}
+
+func (pf PathFilter) Match(filePath string) bool {
+	if pf.excluded(filePath) {
+		return false
+	}
+	return len(pf.Paths) == 0 || matchPath(pf.Paths, filePath)
+}
+
+func (pf PathFilter) excluded(filePath string) bool {
+	return matchPath(pf.ExcludePaths, filePath)
+}
+
+func matchPath(patterns []string, filePath string) bool {
+	filePath = filepath.ToSlash(filePath)
+	for _, pattern := range patterns {
+		if doublestar.MatchUnvalidated(pattern, filePath) {
+			return true
+		}
+	}
+	return false
 }
 
-func (ir IgnoreSymbols) ignore(_ string, s Symbol) bool {
-	if !slices.Contains(ir.Kinds, s.Kind) {
+func matchSymbol(kinds []lsp.SymbolKind, names []string, s Symbol) bool {
+	if !slices.Contains(kinds, s.Kind) {
 		return false
 	}
-	if len(ir.Names) == 0 {
+	if len(names) == 0 {
 		return true
 	}
-	for _, filter := range ir.Names {
+	for _, filter := range names {
 		if match, _ := filepath.Match(filter, s.Name); match {
 			return true
 		}
@@ -0,0 +1,121 @@
+package deadweight
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+const (
+	DefaultLanguage = "go"
+	LanguageAuto    = "auto"
+)
+
+type LanguageServer struct {
+	Name                  string
+	Command               string
+	Args                  []string
+	Extensions            []string
+	LanguageID            string
+	InitializationOptions map[string]any
+	// OpenDocuments sends textDocument/didOpen before querying a file, most
+	// servers other than gopls only answer for opened documents.
+	OpenDocuments bool
+	IgnoreSymbols []IgnoreSymbols
+}
+
+var defaultLanguageServers = []LanguageServer{
+	{
+		Name:       "go",
+		Command:    "gopls",
+		Args:       []string{"-vv"},
+		Extensions: []string{".go"},
+		LanguageID: "go",
+		IgnoreSymbols: []IgnoreSymbols{
+			{Kinds: []lsp.SymbolKind{lsp.SymbolKindFunction}, Names: []string{"main", "init"}},
+			// called by go test
+			{
+				Kinds:      []lsp.SymbolKind{lsp.SymbolKindFunction},
+				Names:      []string{"Test*", "Benchmark*", "Fuzz*", "Example*"},
+				PathFilter: PathFilter{Paths: []string{"**/*_test.go"}},
+			},
+		},
+	},
+	{
+		Name:          "typescript",
+		Command:       "typescript-language-server",
+		Args:          []string{"--stdio"},
+		Extensions:    []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"},
+		LanguageID:    "typescript",
+		OpenDocuments: true,
+		IgnoreSymbols: []IgnoreSymbols{
+			{Kinds: []lsp.SymbolKind{lsp.SymbolKindConstructor}},
+		},
+	},
+	{
+		Name:          "python",
+		Command:       "pyright-langserver",
+		Args:          []string{"--stdio"},
+		Extensions:    []string{".py"},
+		LanguageID:    "python",
+		OpenDocuments: true,
+		IgnoreSymbols: []IgnoreSymbols{
+			{Kinds: []lsp.SymbolKind{lsp.SymbolKindMethod, lsp.SymbolKindFunction}, Names: []string{"__*__"}},
+			{Kinds: []lsp.SymbolKind{lsp.SymbolKindFunction}, Names: []string{"main"}},
+		},
+	},
+	{
+		Name:          "rust",
+		Command:       "rust-analyzer",
+		Extensions:    []string{".rs"},
+		LanguageID:    "rust",
+		OpenDocuments: true,
+		IgnoreSymbols: []IgnoreSymbols{
+			{Kinds: []lsp.SymbolKind{lsp.SymbolKindFunction}, Names: []string{"main"}},
+		},
+	},
+	{
+		Name:          "c",
+		Command:       "clangd",
+		Extensions:    []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".hh"},
+		LanguageID:    "cpp",
+		OpenDocuments: true,
+		IgnoreSymbols: []IgnoreSymbols{
+			{Kinds: []lsp.SymbolKind{lsp.SymbolKindFunction}, Names: []string{"main"}},
+		},
+	},
+}
+
+func (ls LanguageServer) HandlesFile(filePath string) bool {
+	return slices.Contains(ls.Extensions, filepath.Ext(filePath))
+}
+
+// WithLanguage returns a copy of the rules including the ignore rules of the
+// language server.
+func (r Rules) WithLanguage(ls LanguageServer) Rules {
+	r.ignoreSymbols = slices.Concat(r.ignoreSymbols, ls.IgnoreSymbols)
+	return r
+}
+
+func LookupLanguageServer(servers []LanguageServer, name string) (LanguageServer, error) {
+	for _, ls := range servers {
+		if ls.Name == name {
+			return ls, nil
+		}
+	}
+	return LanguageServer{}, fmt.Errorf("unknown language server '%s'", name)
+}
+
+// DetectLanguageServers returns the servers handling at least one of the
+// files.
+func DetectLanguageServers(servers []LanguageServer, files []string) []LanguageServer {
+	var detected []LanguageServer
+	for _, ls := range servers {
+		if slices.ContainsFunc(files, ls.HandlesFile) {
+			detected = append(detected, ls)
+		}
+	}
+	return detected
+}
@@ -0,0 +1,78 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Request is a request, or a notification when it has no ID. IDs are kept raw
+// since peers can use numbers or strings.
+type Request struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+func (r Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// Response answers a request, with either a result or an error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// MarshalJSON writes either the result, even when null, or the error: JSON-RPC
+// forbids both in the same response.
+func (r Response) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			ID      json.RawMessage `json:"id"`
+			Error   *ResponseError  `json:"error"`
+		}{r.JSONRPC, r.ID, r.Error})
+	}
+	type response Response
+	return json.Marshal(response(r))
+}
+
+// ReadMessage reads the body of the next message framed by a Content-Length
+// header, the body is empty when the header is missing.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	var contentLength int
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "Content-Length:") {
+			_, _ = fmt.Sscanf(line, "Content-Length: %d", &contentLength)
+		}
+	}
+	if contentLength == 0 {
+		return nil, nil
+	}
+	body := make([]byte, contentLength)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	return body, nil
+}
+
+func WriteMessage(w io.Writer, payload []byte) error {
+	msg := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(payload), payload)
+	if _, err := io.WriteString(w, msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
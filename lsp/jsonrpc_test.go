@@ -0,0 +1,49 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResponseMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		response Response
+		want     string
+	}{
+		{
+			name: "error",
+			response: Response{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("1"),
+				Error:   &ResponseError{Code: ErrorCodeMethodNotFound, Message: "unknown method"},
+			},
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"unknown method"}}`,
+		},
+		{
+			name:     "error without id",
+			response: Response{JSONRPC: "2.0", Error: &ResponseError{Code: ErrorCodeParseError, Message: "parse error"}},
+			want:     `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`,
+		},
+		{
+			name:     "null result",
+			response: Response{JSONRPC: "2.0", ID: json.RawMessage(`"a"`)},
+			want:     `{"jsonrpc":"2.0","id":"a","result":null}`,
+		},
+		{
+			name:     "result",
+			response: Response{JSONRPC: "2.0", ID: json.RawMessage("2"), Result: []int{1}},
+			want:     `{"jsonrpc":"2.0","id":2,"result":[1]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(tc.response)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Marshal() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,41 @@ import (
 type Message struct {
 	ID     int32           `json:"id"`
 	Result json.RawMessage `json:"result"`
+	Error  *ResponseError  `json:"error"`
+}
+
+type ErrorCode int
+
+const (
+	ErrorCodeParseError           ErrorCode = -32700
+	ErrorCodeInvalidRequest       ErrorCode = -32600
+	ErrorCodeMethodNotFound       ErrorCode = -32601
+	ErrorCodeInvalidParams        ErrorCode = -32602
+	ErrorCodeInternalError        ErrorCode = -32603
+	ErrorCodeServerNotInitialized ErrorCode = -32002
+	ErrorCodeRequestCancelled     ErrorCode = -32800
+	ErrorCodeContentModified      ErrorCode = -32801
+	ErrorCodeServerCancelled      ErrorCode = -32802
+	ErrorCodeRequestFailed        ErrorCode = -32803
+)
+
+type ResponseError struct {
+	Code    ErrorCode       `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *ResponseError) Error() string {
+	if len(e.Data) > 0 {
+		return fmt.Sprintf("%s (code %d, data %s)", e.Message, e.Code, e.Data)
+	}
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Retryable reports whether the request can be sent again, the server gave up
+// on it because the workspace changed or it was busy.
+func (e *ResponseError) Retryable() bool {
+	return e.Code == ErrorCodeContentModified || e.Code == ErrorCodeServerCancelled
 }
 
 type Position struct {
@@ -21,7 +56,8 @@ type Range struct {
 }
 
 type Location struct {
-	URI string `json:"uri"`
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
 }
 
 type SymbolKind int
@@ -103,7 +139,48 @@ func ParseSymbolKind(s string) (SymbolKind, error) {
 type DocumentSymbol struct {
 	Name           string           `json:"name"`
 	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
 	SelectionRange Range            `json:"selectionRange"`
 	Children       []DocumentSymbol `json:"children"`
 	Detail         string           `json:"detail"`
 }
+
+func (p Position) Before(other Position) bool {
+	return p.Line < other.Line || (p.Line == other.Line && p.Character < other.Character)
+}
+
+func (r Range) Contains(p Position) bool {
+	return !p.Before(r.Start) && !r.End.Before(p)
+}
+
+func (r Range) Intersects(other Range) bool {
+	return !r.End.Before(other.Start) && !other.End.Before(r.Start)
+}
+
+type DiagnosticSeverity int
+
+const (
+	DiagnosticSeverityError       DiagnosticSeverity = 1
+	DiagnosticSeverityWarning     DiagnosticSeverity = 2
+	DiagnosticSeverityInformation DiagnosticSeverity = 3
+	DiagnosticSeverityHint        DiagnosticSeverity = 4
+)
+
+type DiagnosticTag int
+
+// DiagnosticTagUnnecessary lets editors fade out the code.
+const DiagnosticTagUnnecessary DiagnosticTag = 1
+
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Code     string             `json:"code,omitempty"`
+	Source   string             `json:"source"`
+	Message  string             `json:"message"`
+	Tags     []DiagnosticTag    `json:"tags,omitempty"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
@@ -2,16 +2,21 @@ package deadweight
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/theo303/deadweight/lsp"
 )
@@ -21,27 +26,66 @@ type lspClient struct {
 
 	wg sync.WaitGroup
 
-	pipeIn io.Writer
-	ready  chan struct{}
+	pipeIn  io.WriteCloser
+	writeMu sync.Mutex
+	ready   chan error
 
-	pendingMessages sync.Map // map[int32]messageHandler
+	// done is closed once the server process exited and every pending
+	// request has been failed.
+	done     chan struct{}
+	exitErr  error
+	shutdown atomic.Bool
+
+	pendingMessages sync.Map // map[int32]*pendingRequest
 	idCounter       atomic.Int32
+	inFlight        chan struct{}
+	stats           requestStats
+
+	root    string
+	server  LanguageServer
+	options ClientOptions
 
-	root string
+	rules  atomic.Pointer[Rules]
+	cache  atomic.Pointer[Cache]
+	logger *slog.Logger
+
+	// documents holds the version of the documents opened in the server.
+	documents   map[string]int
+	documentsMu sync.Mutex
+}
 
-	rules Rules
+type pendingRequest struct {
+	method  string
+	handler messageHandler
+	timer   *time.Timer
+	sentAt  time.Time
+	limited bool
+	// stopInterrupt stops cancelling the request once the context it was sent
+	// with is done.
+	stopInterrupt func() bool
 }
 
-func NewLSPClient(ctx context.Context, root string, rules Rules) (*lspClient, error) {
-	cmd := exec.CommandContext(ctx, "gopls", "-vv")
+// NewLSPClient creates a client for the language server. Requests are
+// cancelled once the context they were sent with is done.
+func NewLSPClient(ctx context.Context, root string, server LanguageServer, rules Rules, options ClientOptions) (*lspClient, error) {
+	cmd := exec.Command(server.Command, server.Args...)
 
 	lc := &lspClient{
 		cmd:             cmd,
 		wg:              sync.WaitGroup{},
-		ready:           make(chan struct{}, 1),
+		ready:           make(chan error, 1),
+		done:            make(chan struct{}),
 		pendingMessages: sync.Map{},
 		root:            root,
-		rules:           rules,
+		server:          server,
+		options:         options,
+		documents:       make(map[string]int),
+		logger:          cmp.Or(options.Logger, discardLogger),
+	}
+	lc.SetRules(rules)
+	lc.cache.Store(options.Cache)
+	if options.MaxInFlight > 0 {
+		lc.inFlight = make(chan struct{}, options.MaxInFlight)
 	}
 
 	pipeOut, err := cmd.StdoutPipe()
@@ -67,29 +111,37 @@ func (lc *lspClient) RunAndInitialize(ctx context.Context) error {
 	if err := lc.cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start command: %w", err)
 	}
-	slog.Debug("lsp client running")
-
-	if err := lc.sendCommand("initialize",
-		map[string]any{
-			"processId": nil,
-			"rootUri":   lc.root,
-			"capabilities": map[string]any{
-				"textDocument": map[string]any{
-					"documentSymbol": map[string]any{
-						"hierarchicalDocumentSymbolSupport": true,
-					},
+	lc.logger.Debug("lsp client running")
+	go lc.watchExit()
+
+	params := map[string]any{
+		"processId": nil,
+		"rootUri":   lc.root,
+		"workspaceFolders": []map[string]any{
+			{"uri": lc.root, "name": path.Base(lc.root)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"documentSymbol": map[string]any{
+					"hierarchicalDocumentSymbolSupport": true,
 				},
 			},
-			"trace": "off",
 		},
-		initializeResponse(lc.ready),
-	); err != nil {
+		"trace": "off",
+	}
+	if lc.server.InitializationOptions != nil {
+		params["initializationOptions"] = lc.server.InitializationOptions
+	}
+	if err := lc.sendCommand(ctx, "initialize", params, initializeResponse(lc.ready)); err != nil {
 		return fmt.Errorf("failed to send intialize command: %w", err)
 	}
 
 	select {
-	case <-lc.ready:
-		if err := lc.sendCommand("initialized", map[string]any{}, nil); err != nil {
+	case err := <-lc.ready:
+		if err != nil {
+			return fmt.Errorf("failed to initialize: %w", err)
+		}
+		if err := lc.sendNotification("initialized", map[string]any{}); err != nil {
 			return fmt.Errorf("failed to send intialized command: %w", err)
 		}
 	case <-ctx.Done():
@@ -99,61 +151,322 @@ func (lc *lspClient) RunAndInitialize(ctx context.Context) error {
 	return nil
 }
 
-func (lc *lspClient) Wait() {
-	_ = lc.cmd.Wait()
+// watchExit waits for the server process to exit and fails every pending
+// request, so that callers waiting on responses are released.
+func (lc *lspClient) watchExit() {
 	lc.wg.Wait()
-	slog.Debug("lsp client exited")
+	lc.exitErr = lc.cmd.Wait()
+	if !lc.shutdown.Load() {
+		lc.logger.Error("language server exited unexpectedly",
+			slog.String("server", lc.server.Name),
+			slog.Any("error", lc.exitErr),
+		)
+	}
+	lc.failPending()
+	close(lc.done)
+}
+
+func (lc *lspClient) failPending() {
+	err := fmt.Errorf("language server %s exited", lc.server.Name)
+	if lc.exitErr != nil {
+		err = fmt.Errorf("language server %s exited: %w", lc.server.Name, lc.exitErr)
+	}
+	lc.pendingMessages.Range(func(key, _ any) bool {
+		if req, ok := lc.take(key.(int32)); ok {
+			go req.handler(errorMessage(key.(int32), lsp.ErrorCodeRequestFailed, err))
+		}
+		return true
+	})
+}
+
+// take removes a pending request, it returns false if the request has
+// already been answered, failed or cancelled.
+func (lc *lspClient) take(id int32) (*pendingRequest, bool) {
+	value, ok := lc.pendingMessages.LoadAndDelete(id)
+	if !ok {
+		return nil, false
+	}
+	req := value.(*pendingRequest)
+	if req.timer != nil {
+		req.timer.Stop()
+	}
+	if req.stopInterrupt != nil {
+		req.stopInterrupt()
+	}
+	lc.stats.complete(time.Since(req.sentAt))
+	if req.limited {
+		lc.release()
+	}
+	return req, true
+}
+
+// acquire blocks until the number of requests waiting for a response is
+// below the configured limit.
+func (lc *lspClient) acquire(ctx context.Context) error {
+	if lc.inFlight == nil {
+		return nil
+	}
+	select {
+	case lc.inFlight <- struct{}{}:
+		return nil
+	case <-lc.done:
+		return fmt.Errorf("language server %s exited", lc.server.Name)
+	case <-ctx.Done():
+		return errInterrupted
+	}
+}
+
+func (lc *lspClient) release() {
+	if lc.inFlight != nil {
+		<-lc.inFlight
+	}
+}
+
+// cancel sends $/cancelRequest for a pending request and fails it with a
+// RequestCancelled error.
+func (lc *lspClient) cancel(id int32, reason error) {
+	req, ok := lc.take(id)
+	if !ok {
+		return
+	}
+	lc.logger.Debug("cancelling request", slog.String("method", req.method), slog.Any("id", id), slog.Any("reason", reason))
+	if err := lc.sendNotification("$/cancelRequest", map[string]any{"id": id}); err != nil {
+		lc.logger.Debug("failed to send $/cancelRequest notification", slog.Any("error", err))
+	}
+	go req.handler(errorMessage(id, lsp.ErrorCodeRequestCancelled, fmt.Errorf("%s: %w", req.method, reason)))
+}
+
+// Err returns an error if the server exited without being shut down.
+func (lc *lspClient) Err() error {
+	select {
+	case <-lc.done:
+	default:
+		return nil
+	}
+	if lc.shutdown.Load() {
+		return nil
+	}
+	if lc.exitErr != nil {
+		return fmt.Errorf("language server %s exited unexpectedly: %w", lc.server.Name, lc.exitErr)
+	}
+	return fmt.Errorf("language server %s exited unexpectedly", lc.server.Name)
+}
+
+// Stats returns the statistics of the requests sent to the server.
+func (lc *lspClient) Stats() RequestStats {
+	return lc.stats.snapshot(lc.server.Name)
 }
 
-func (lc *lspClient) ListDocumentSymbols(filePath string, wg *sync.WaitGroup, symbols *SymbolMap) error {
+// Shutdown sends the shutdown request then the exit notification, the server
+// is killed if it did not exit before ctx is done.
+func (lc *lspClient) Shutdown(ctx context.Context) error {
+	if lc.cmd.Process == nil {
+		return nil
+	}
+	select {
+	case <-lc.done:
+		return lc.Err()
+	default:
+	}
+	lc.shutdown.Store(true)
+
+	response := make(chan *lsp.ResponseError, 1)
+	if err := lc.send(ctx, "shutdown", nil, func(m lsp.Message) {
+		response <- m.Error
+	}, false); err != nil {
+		lc.logger.Debug("failed to send shutdown request", slog.String("server", lc.server.Name), slog.Any("error", err))
+	} else {
+		select {
+		case respErr := <-response:
+			if respErr != nil {
+				lc.logger.Debug("shutdown request failed", slog.String("server", lc.server.Name), slog.Any("error", respErr))
+			}
+		case <-ctx.Done():
+		}
+	}
+
+	if err := lc.sendNotification("exit", nil); err != nil {
+		lc.logger.Debug("failed to send exit notification", slog.String("server", lc.server.Name), slog.Any("error", err))
+	}
+	_ = lc.pipeIn.Close()
 
-	if err := lc.sendCommand("textDocument/documentSymbol",
+	select {
+	case <-lc.done:
+	case <-ctx.Done():
+		lc.logger.Warn("language server did not exit, killing it", slog.String("server", lc.server.Name))
+		_ = lc.cmd.Process.Kill()
+		<-lc.done
+	}
+	lc.logger.Debug("lsp client exited")
+	return nil
+}
+
+func (lc *lspClient) ListDocumentSymbols(ctx context.Context, filePath string, wg *sync.WaitGroup, symbols *SymbolMap) error {
+	if lc.server.OpenDocuments {
+		if err := lc.openDocument(filePath); err != nil {
+			wg.Done()
+			return err
+		}
+	}
+
+	if cached, ok := lc.cache.Load().Symbols(lc.server, filePath); ok {
+		lc.storeSymbols(symbols, filePath, cached)
+		wg.Done()
+		return nil
+	}
+
+	if err := lc.sendCommand(ctx, "textDocument/documentSymbol",
 		map[string]any{
 			"textDocument": map[string]any{
 				"uri": lc.root + "/" + filePath,
 			},
 		},
-		lc.documentSymbolResponse(wg, symbols, filePath),
+		lc.documentSymbolResponse(ctx, wg, symbols, filePath),
 	); err != nil {
 		wg.Done()
+		if errors.Is(err, errInterrupted) {
+			// the report of an interrupted analysis is partial
+			return nil
+		}
 		return fmt.Errorf("failed to send workspace/symbol command: %w", err)
 	}
 	return nil
 }
 
-func (lc *lspClient) ReferencesSymbols(allSymbols *SymbolMap) (*ReferenceMap, error) {
-	defer allSymbols.Unlock()
+// SetCache replaces the cache used to skip requests, nil disables it.
+func (lc *lspClient) SetCache(cache *Cache) {
+	lc.cache.Store(cache)
+}
+
+func (lc *lspClient) SetRules(rules Rules) {
+	rules = rules.WithLanguage(lc.server)
+	lc.rules.Store(&rules)
+}
+
+// openDocument opens a document with its content on disk, unless it is
+// already open.
+func (lc *lspClient) openDocument(filePath string) error {
+	defer lc.documentsMu.Unlock()
+	lc.documentsMu.Lock()
+	if _, ok := lc.documents[filePath]; ok {
+		return nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(strings.TrimPrefix(lc.root, "file://"), filePath))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	return lc.didOpen(filePath, content)
+}
+
+func (lc *lspClient) didOpen(filePath string, content []byte) error {
+	if err := lc.sendNotification("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{
+			"uri":        lc.root + "/" + filePath,
+			"languageId": lc.server.LanguageID,
+			"version":    1,
+			"text":       string(content),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to send textDocument/didOpen notification: %w", err)
+	}
+	lc.documents[filePath] = 1
+	return nil
+}
+
+// UpdateDocument replaces the content of a document in the server, opening
+// it if needed.
+func (lc *lspClient) UpdateDocument(filePath string, content []byte) error {
+	defer lc.documentsMu.Unlock()
+	lc.documentsMu.Lock()
+	version, ok := lc.documents[filePath]
+	if !ok {
+		return lc.didOpen(filePath, content)
+	}
+
+	if err := lc.sendNotification("textDocument/didChange", map[string]any{
+		"textDocument": map[string]any{
+			"uri":     lc.root + "/" + filePath,
+			"version": version + 1,
+		},
+		"contentChanges": []map[string]any{
+			{"text": string(content)},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to send textDocument/didChange notification: %w", err)
+	}
+	lc.documents[filePath] = version + 1
+	return nil
+}
+
+// CloseDocument closes a document, the server reads it from the disk again.
+func (lc *lspClient) CloseDocument(filePath string) error {
+	defer lc.documentsMu.Unlock()
+	lc.documentsMu.Lock()
+	if _, ok := lc.documents[filePath]; !ok {
+		return nil
+	}
 
+	if err := lc.sendNotification("textDocument/didClose", map[string]any{
+		"textDocument": map[string]any{
+			"uri": lc.root + "/" + filePath,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to send textDocument/didClose notification: %w", err)
+	}
+	delete(lc.documents, filePath)
+	return nil
+}
+
+// ReferencesSymbols resolves the references of the symbols handled by the
+// server, onResolved is called with each of them when not nil.
+func (lc *lspClient) ReferencesSymbols(ctx context.Context, allSymbols *SymbolMap, onResolved ReferencesFunc) (*ReferenceMap, error) {
 	wg := &sync.WaitGroup{}
 	references := NewReferenceMap()
+	references.onStore = onResolved
 
-	allSymbols.Lock()
-	for filePath, symbols := range allSymbols.m {
+	var err error
+symbols:
+	for filePath, symbols := range allSymbols.snapshot() {
+		if !lc.server.HandlesFile(filePath) {
+			continue
+		}
 		for _, symbol := range symbols {
 			wg.Add(1)
-			if err := lc.references(
+			if err = lc.references(
+				ctx,
 				wg,
 				references,
 				filePath,
 				symbol,
 			); err != nil {
-				return nil, err
+				break symbols
 			}
 		}
 	}
+	// pending handlers still store their references
 	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
 
 	return references, nil
 }
 
 func (lc *lspClient) references(
+	ctx context.Context,
 	wg *sync.WaitGroup,
 	references *ReferenceMap,
 	filePath string,
 	symbol Symbol,
 ) error {
+	if cached, ok := lc.cache.Load().References(lc.server, filePath, symbol); ok {
+		references.Store(filePath, symbol, cached)
+		wg.Done()
+		return nil
+	}
 
-	if err := lc.sendCommand("textDocument/references",
+	if err := lc.sendCommand(ctx, "textDocument/references",
 		map[string]any{
 			"textDocument": map[string]any{
 				"uri": lc.root + "/" + filePath,
@@ -166,16 +479,57 @@ func (lc *lspClient) references(
 				"includeDeclaration": false,
 			},
 		},
-		referencesResponse(wg, references, filePath, symbol),
+		lc.referencesResponse(wg, references, filePath, symbol),
 	); err != nil {
 		wg.Done()
+		if errors.Is(err, errInterrupted) {
+			// unresolved like the requests cancelled once sent
+			references.StoreError(filePath, symbol, &lsp.ResponseError{Code: lsp.ErrorCodeRequestCancelled, Message: err.Error()})
+			return nil
+		}
 		return fmt.Errorf("failed to send textDocument/references command: %w", err)
 	}
 	return nil
 }
 
-func (lc *lspClient) isEmbedded(filePath string, documentSymbol lsp.DocumentSymbol) (bool, error) {
-	if documentSymbol.Kind != lsp.SymbolKindField {
+// ImplementationsSymbols stores in references the interface methods
+// implemented by the methods handled by the server.
+func (lc *lspClient) ImplementationsSymbols(ctx context.Context, methods *SymbolMap, references *ReferenceMap) error {
+	wg := &sync.WaitGroup{}
+	// pending handlers still store their implementations
+	defer wg.Wait()
+	for filePath, symbols := range methods.snapshot() {
+		if !lc.server.HandlesFile(filePath) {
+			continue
+		}
+		for _, symbol := range symbols {
+			wg.Add(1)
+			if err := lc.sendCommand(ctx, "textDocument/implementation",
+				map[string]any{
+					"textDocument": map[string]any{
+						"uri": lc.root + "/" + filePath,
+					},
+					"position": map[string]any{
+						"line":      symbol.Position.Line,
+						"character": symbol.Position.Character,
+					},
+				},
+				lc.implementationResponse(wg, references, filePath, symbol),
+			); err != nil {
+				wg.Done()
+				if errors.Is(err, errInterrupted) {
+					// the methods are then only used through their references
+					return nil
+				}
+				return fmt.Errorf("failed to send textDocument/implementation command: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (lc *lspClient) isEmbedded(ctx context.Context, filePath string, documentSymbol lsp.DocumentSymbol) (bool, error) {
+	if lc.server.LanguageID != "go" || documentSymbol.Kind != lsp.SymbolKindField {
 		return false, nil
 	}
 	detailSplit := strings.Split(documentSymbol.Detail, ".")
@@ -192,20 +546,24 @@ func (lc *lspClient) isEmbedded(filePath string, documentSymbol lsp.DocumentSymb
 
 	packageName := detailSplit[0]
 
-	hasSymbol := make(chan bool)
+	hasSymbol := make(chan positionSymbol)
 	defer close(hasSymbol)
 
 	pos := documentSymbol.SelectionRange.End
 	pos.Character += len(packageName) + 2
-	if err := lc.positionHasSymbol(filePath, pos, hasSymbol); err != nil {
+	if err := lc.positionHasSymbol(ctx, filePath, pos, hasSymbol); err != nil {
 		return false, err
 	}
-	return !<-hasSymbol, nil
+	result := <-hasSymbol
+	if result.err != nil {
+		return false, result.err
+	}
+	return !result.found, nil
 }
 
-func (lc *lspClient) positionHasSymbol(filePath string, position lsp.Position, hasSymbol chan bool) error {
+func (lc *lspClient) positionHasSymbol(ctx context.Context, filePath string, position lsp.Position, hasSymbol chan positionSymbol) error {
 
-	if err := lc.sendCommand("textDocument/definition", map[string]any{
+	if err := lc.sendCommand(ctx, "textDocument/definition", map[string]any{
 		"textDocument": map[string]any{
 			"uri": lc.root + "/" + filePath,
 		},
@@ -226,14 +584,77 @@ type command struct {
 	JSONRPC string         `json:"jsonrpc"`
 	ID      int32          `json:"id"`
 	Method  string         `json:"method"`
-	Params  map[string]any `json:"params"`
+	Params  map[string]any `json:"params,omitempty"`
 }
 
-func (lc *lspClient) sendCommand(method string, params map[string]any, handler messageHandler) error {
-	id := lc.idCounter.Add(1)
+type notification struct {
+	JSONRPC string         `json:"jsonrpc"`
+	Method  string         `json:"method"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+const (
+	maxRetries = 3
+	retryDelay = 100 * time.Millisecond
+)
+
+// errInterrupted fails the requests whose context is done.
+var errInterrupted = errors.New("interrupted")
+
+func (lc *lspClient) sendCommand(ctx context.Context, method string, params map[string]any, handler messageHandler) error {
+	if handler != nil {
+		handler = lc.retryHandler(ctx, method, params, handler, 1)
+	}
+	return lc.sendLimited(ctx, method, params, handler)
+}
+
+// sendLimited sends a request once the in-flight limit allows it.
+func (lc *lspClient) sendLimited(ctx context.Context, method string, params map[string]any, handler messageHandler) error {
 	if handler != nil {
-		lc.pendingMessages.Store(id, handler)
+		if err := lc.acquire(ctx); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+	if ctx.Err() != nil {
+		if handler != nil {
+			lc.release()
+		}
+		return fmt.Errorf("%s: %w", method, errInterrupted)
+	}
+	return lc.send(ctx, method, params, handler, handler != nil)
+}
+
+// retryHandler sends the request again when the server answers with a
+// retryable error, other responses are passed to handler.
+func (lc *lspClient) retryHandler(ctx context.Context, method string, params map[string]any, handler messageHandler, attempt int) messageHandler {
+	return func(m lsp.Message) {
+		if m.Error == nil || !m.Error.Retryable() || attempt > maxRetries {
+			handler(m)
+			return
+		}
+		lc.logger.Debug("retrying request", slog.String("method", method), slog.Int("attempt", attempt), slog.Any("error", m.Error))
+		time.Sleep(retryDelay * time.Duration(attempt))
+		if err := lc.sendLimited(ctx, method, params, lc.retryHandler(ctx, method, params, handler, attempt+1)); err != nil {
+			handler(errorMessage(m.ID, lsp.ErrorCodeRequestFailed, err))
+		}
 	}
+}
+
+func errorMessage(id int32, code lsp.ErrorCode, err error) lsp.Message {
+	return lsp.Message{
+		ID: id,
+		Error: &lsp.ResponseError{
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
+
+// send writes a request, cancelled once ctx is done. When it returns an error
+// the handler has not been and will not be called. limited requests hold an
+// in-flight slot that is released once they are answered.
+func (lc *lspClient) send(ctx context.Context, method string, params map[string]any, handler messageHandler, limited bool) error {
+	id := lc.idCounter.Add(1)
 
 	payload, err := json.Marshal(command{
 		JSONRPC: "2.0",
@@ -242,64 +663,130 @@ func (lc *lspClient) sendCommand(method string, params map[string]any, handler m
 		Params:  params,
 	})
 	if err != nil {
+		if limited {
+			lc.release()
+		}
 		return fmt.Errorf("failed to marshal command: %w", err)
 	}
-	msg := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(payload), payload)
-	if _, err := io.WriteString(lc.pipeIn, msg); err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+
+	if handler != nil {
+		lc.stats.send()
+		req := &pendingRequest{method: method, handler: handler, sentAt: time.Now(), limited: limited}
+		if timeout := lc.options.Timeouts.For(method); timeout > 0 {
+			req.timer = time.AfterFunc(timeout, func() {
+				lc.cancel(id, fmt.Errorf("timed out after %s", timeout))
+			})
+		}
+		req.stopInterrupt = context.AfterFunc(ctx, func() {
+			lc.cancel(id, errInterrupted)
+		})
+		lc.pendingMessages.Store(id, req)
+		select {
+		case <-lc.done:
+			if _, ok := lc.take(id); ok {
+				return fmt.Errorf("language server %s exited", lc.server.Name)
+			}
+			return nil
+		case <-ctx.Done():
+			if _, ok := lc.take(id); ok {
+				return fmt.Errorf("%s: %w", method, errInterrupted)
+			}
+			return nil
+		default:
+		}
+	}
+
+	if err := lc.write(payload); err != nil {
+		if _, ok := lc.take(id); ok || handler == nil {
+			return err
+		}
+		// the handler has already been failed or cancelled
+		return nil
 	}
 	return nil
 }
 
+func (lc *lspClient) sendNotification(method string, params map[string]any) error {
+	payload, err := json.Marshal(notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return lc.write(payload)
+}
+
+func (lc *lspClient) write(payload []byte) error {
+	lc.writeMu.Lock()
+	defer lc.writeMu.Unlock()
+	return lsp.WriteMessage(lc.pipeIn, payload)
+}
+
+// replyToServer answers the requests sent by the server, like
+// workspace/configuration, with empty results: the client has no setting
+// and registers no capability dynamically.
+func (lc *lspClient) replyToServer(request lsp.Request) {
+	response := lsp.Response{JSONRPC: "2.0", ID: request.ID}
+	if request.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		response.Result = make([]any, len(params.Items))
+	}
+	payload, err := json.Marshal(response)
+	if err != nil {
+		lc.logger.Error("failed to marshal response", slog.Any("error", err))
+		return
+	}
+	if err := lc.write(payload); err != nil {
+		lc.logger.Error("failed to reply to language server", slog.String("method", request.Method), slog.Any("error", err))
+	}
+}
+
 func (lc *lspClient) readStdOut(r io.Reader) {
 	reader := bufio.NewReader(r)
 	lc.wg.Go(func() {
 		for {
-			// Read headers
-			var contentLength int
-			for {
-				line, err := reader.ReadString('\n')
-				if err != nil {
-					if !errors.Is(err, io.EOF) {
-						slog.Error("failed to read headers from STDOUT", slog.Any("error", err))
-					}
-					return
-				}
-				line = strings.TrimSpace(line)
-				if line == "" {
-					break
-				}
-				if strings.HasPrefix(line, "Content-Length:") {
-					_, _ = fmt.Sscanf(line, "Content-Length: %d", &contentLength)
+			body, err := lsp.ReadMessage(reader)
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					lc.logger.Error("failed to read message from STDOUT", slog.Any("error", err))
 				}
+				return
 			}
-			if contentLength == 0 {
-				slog.Warn("received empty response from gopls")
+			if len(body) == 0 {
+				lc.logger.Warn("received empty response from language server", slog.String("server", lc.server.Name))
 				continue
 			}
-			body := make([]byte, contentLength)
-			if _, err := io.ReadFull(reader, body); err != nil {
-				slog.Error("failed to read body from STDOUT", slog.Any("error", err))
+
+			var request lsp.Request
+			if err := json.Unmarshal(body, &request); err == nil && request.Method != "" {
+				if !request.IsNotification() {
+					lc.replyToServer(request)
+				}
 				continue
 			}
 
 			var msg lsp.Message
 			if err := json.Unmarshal(body, &msg); err != nil {
-				slog.Error("failed to unmarshal message", slog.Any("error", err))
+				lc.logger.Error("failed to unmarshal message", slog.Any("error", err))
 				continue
 			}
 
-			slog.Debug("message received", slog.String("body", string(body)), slog.Any("id", msg.ID))
+			lc.logger.Debug("message received", slog.String("body", string(body)), slog.Any("id", msg.ID))
 			if msg.ID == 0 {
 				continue
 			}
 
-			value, ok := lc.pendingMessages.LoadAndDelete(msg.ID)
+			req, ok := lc.take(msg.ID)
 			if !ok {
-				slog.Debug("id not in map, ignored", slog.Any("id", msg.ID))
+				lc.logger.Debug("id not in map, ignored", slog.Any("id", msg.ID))
 				continue
 			}
-			go value.(messageHandler)(msg)
+			go req.handler(msg)
 		}
 	})
 }
@@ -312,7 +799,7 @@ func (lc *lspClient) logStrErr(r io.Reader) {
 				line, err := reader.ReadString('\n')
 				if err != nil {
 					if !errors.Is(err, io.EOF) {
-						slog.Error("failed to read from STDERR", slog.Any("error", err))
+						lc.logger.Error("failed to read from STDERR", slog.Any("error", err))
 					}
 					return
 				}
@@ -320,7 +807,7 @@ func (lc *lspClient) logStrErr(r io.Reader) {
 				if line == "" {
 					break
 				}
-				slog.Error("error from gopls", slog.Any("error", line))
+				lc.logger.Error("error from language server", slog.String("server", lc.server.Name), slog.Any("error", line))
 			}
 		}
 	})
@@ -0,0 +1,139 @@
+package deadweight
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+type Reachability struct {
+	Enabled     bool
+	Exported    bool
+	Entrypoints []Entrypoint
+}
+
+type Entrypoint struct {
+	Kinds []lsp.SymbolKind
+	Names []string
+	PathFilter
+}
+
+var defaultEntrypoints = []Entrypoint{
+	{Kinds: []lsp.SymbolKind{lsp.SymbolKindFunction}, Names: []string{"main", "init"}},
+}
+
+func (r Reachability) isRoot(filePath string, s Symbol) bool {
+	if r.Exported && isExported(s.Name) {
+		return true
+	}
+	for _, e := range r.Entrypoints {
+		if e.Match(filePath) && matchSymbol(e.Kinds, e.Names, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExported reports whether the last segment of a symbol name starts with an
+// upper case letter, gopls names methods '(*Type).Method'.
+func isExported(name string) bool {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+type symbolNode struct {
+	filePath string
+	symbol   Symbol
+}
+
+// GetUnreachableSymbols returns the symbols that cannot be reached from a root
+// by following references. A reference that is not located inside an analyzed
+// symbol (ignored symbols, top level statements, files that are not analyzed)
+// makes the referenced symbol a root. References from test files are only
+// followed with countTests or to symbols declared in test files.
+func (rm *ReferenceMap) GetUnreachableSymbols(root string, reachability Reachability, countTests bool) *SymbolMap {
+	defer rm.Unlock()
+	rm.Lock()
+
+	edges := make(map[symbolNode][]symbolNode)
+	reached := make(map[symbolNode]bool)
+	var queue []symbolNode
+
+	for filePath, symbols := range rm.m {
+		for symbol, references := range symbols {
+			node := symbolNode{filePath: filePath, symbol: symbol}
+			isRoot := reachability.isRoot(filePath, symbol)
+			for _, reference := range references {
+				if !rm.counter.countsAsUse(reference, countTests || isTestFile(filePath)) {
+					continue
+				}
+				from, ok := rm.enclosingSymbol(root, reference)
+				if !ok {
+					isRoot = true
+					continue
+				}
+				edges[from] = append(edges[from], node)
+			}
+			// a method is reached through the interface methods it implements
+			for _, implementation := range rm.implementations[filePath][symbol] {
+				from, ok := rm.enclosingSymbol(root, implementation)
+				if !ok {
+					isRoot = true
+					continue
+				}
+				edges[from] = append(edges[from], node)
+			}
+			if isRoot {
+				reached[node] = true
+				queue = append(queue, node)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[node] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	unreachable := NewSymbolMap()
+	for filePath, symbols := range rm.m {
+		for symbol := range symbols {
+			if !reached[symbolNode{filePath: filePath, symbol: symbol}] {
+				unreachable.Add(filePath, symbol)
+			}
+		}
+	}
+	return unreachable
+}
+
+func (rm *ReferenceMap) enclosingSymbol(root string, reference lsp.Location) (symbolNode, bool) {
+	filePath, ok := strings.CutPrefix(reference.URI, root+"/")
+	if !ok {
+		return symbolNode{}, false
+	}
+
+	var enclosing Symbol
+	found := false
+	for symbol := range rm.m[filePath] {
+		if !symbol.Range.Contains(reference.Range.Start) {
+			continue
+		}
+		if !found || enclosing.Range.Start.Before(symbol.Range.Start) ||
+			(enclosing.Range.Start == symbol.Range.Start && symbol.Range.End.Before(enclosing.Range.End)) {
+			enclosing = symbol
+			found = true
+		}
+	}
+	return symbolNode{filePath: filePath, symbol: enclosing}, found
+}
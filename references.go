@@ -1,39 +1,100 @@
 package deadweight
 
 import (
+	"maps"
 	"strings"
 	"sync"
 
 	"github.com/theo303/deadweight/lsp"
 )
 
+// ReferencesFunc is called with the references of a symbol as soon as they
+// are resolved.
+type ReferencesFunc func(filePath string, symbol Symbol, references []lsp.Location)
+
 type ReferenceMap struct {
-	m map[string]map[Symbol][]lsp.Location
+	m      map[string]map[Symbol][]lsp.Location
+	errors map[string]map[Symbol]error
+	// implementations holds the interface methods implemented by methods.
+	implementations map[string]map[Symbol][]lsp.Location
+	// onStore is called outside of the lock by Store when not nil.
+	onStore ReferencesFunc
+	counter referenceCounter
 
 	sync.Mutex
 }
 
 func NewReferenceMap() *ReferenceMap {
 	return &ReferenceMap{
-		m: make(map[string]map[Symbol][]lsp.Location),
+		m:               make(map[string]map[Symbol][]lsp.Location),
+		errors:          make(map[string]map[Symbol]error),
+		implementations: make(map[string]map[Symbol][]lsp.Location),
 	}
 }
 
 func (rm *ReferenceMap) Store(filePath string, symbol Symbol, referencesURIs []lsp.Location) {
-	defer rm.Unlock()
 	rm.Lock()
 	if rm.m[filePath] == nil {
 		rm.m[filePath] = make(map[Symbol][]lsp.Location)
 	}
 	rm.m[filePath][symbol] = referencesURIs
+	rm.Unlock()
+
+	if rm.onStore != nil {
+		rm.onStore(filePath, symbol, referencesURIs)
+	}
 }
 
-func (rm *ReferenceMap) GetUnusedSymbols() *SymbolMap {
+// StoreError records a symbol whose references could not be resolved, it is
+// neither used nor unused.
+func (rm *ReferenceMap) StoreError(filePath string, symbol Symbol, err error) {
+	defer rm.Unlock()
+	rm.Lock()
+	if rm.errors[filePath] == nil {
+		rm.errors[filePath] = make(map[Symbol]error)
+	}
+	rm.errors[filePath][symbol] = err
+}
+
+// StoreImplementations records the interface methods implemented by a method.
+func (rm *ReferenceMap) StoreImplementations(filePath string, symbol Symbol, implementations []lsp.Location) {
+	defer rm.Unlock()
+	rm.Lock()
+	if rm.implementations[filePath] == nil {
+		rm.implementations[filePath] = make(map[Symbol][]lsp.Location)
+	}
+	rm.implementations[filePath][symbol] = implementations
+}
+
+func (rm *ReferenceMap) Merge(other *ReferenceMap) {
+	defer rm.Unlock()
+	defer other.Unlock()
+	rm.Lock()
+	other.Lock()
+	for filePath, symbols := range other.m {
+		if rm.m[filePath] == nil {
+			rm.m[filePath] = make(map[Symbol][]lsp.Location, len(symbols))
+		}
+		maps.Copy(rm.m[filePath], symbols)
+	}
+	for filePath, symbols := range other.errors {
+		if rm.errors[filePath] == nil {
+			rm.errors[filePath] = make(map[Symbol]error, len(symbols))
+		}
+		maps.Copy(rm.errors[filePath], symbols)
+	}
+}
+
+// GetUnusedSymbols returns the symbols without references, references from
+// test files only count with countTests or for symbols declared in test files.
+func (rm *ReferenceMap) GetUnusedSymbols(countTests bool) *SymbolMap {
+	defer rm.Unlock()
+	rm.Lock()
 	unusedSymbols := NewSymbolMap()
 
 	for filePath, symbols := range rm.m {
 		for symbol, references := range symbols {
-			if !isUsed(references) {
+			if !rm.counter.isUsed(references, countTests || isTestFile(filePath)) && !rm.usedViaInterface(filePath, symbol, countTests) {
 				unusedSymbols.Add(filePath, symbol)
 			}
 		}
@@ -42,12 +103,111 @@ func (rm *ReferenceMap) GetUnusedSymbols() *SymbolMap {
 	return unusedSymbols
 }
 
-func isUsed(references []lsp.Location) bool {
+// usedViaInterface reports whether a method implements an interface method
+// that is used, declared outside of the analyzed symbols or whose use is
+// unknown because it was not looked up.
+func (rm *ReferenceMap) usedViaInterface(filePath string, symbol Symbol, countTests bool) bool {
+	for _, implementation := range rm.implementations[filePath][symbol] {
+		node, ok := rm.enclosingSymbol(rm.counter.root, implementation)
+		if !ok {
+			return true
+		}
+		references, ok := rm.m[node.filePath][node.symbol]
+		if !ok || rm.counter.isUsed(references, countTests || isTestFile(node.filePath)) {
+			return true
+		}
+	}
+	return false
+}
+
+type ReferenceCounts struct {
+	Total    int `json:"total"`
+	Test     int `json:"test"`
+	Excluded int `json:"excluded"`
+	// ExcludedBy counts the excluded references per exclusion rule.
+	ExcludedBy map[string]int `json:"excludedBy,omitempty"`
+}
+
+// ReferenceExclusion discounts the references located in the files matching
+// its paths, these files are not scanned for symbols either.
+type ReferenceExclusion struct {
+	Name  string
+	Paths []string
+}
+
+var defaultReferenceExclusions = []ReferenceExclusion{
+	{Name: "mocks", Paths: []string{"**/mock/**", "**/mocks/**", "**/mock_*.go", "**/*_mock.go"}},
+}
+
+// referenceCounter counts the references of the symbols, root is the URI the
+// paths of the exclusions are relative to.
+type referenceCounter struct {
+	root       string
+	exclusions []ReferenceExclusion
+	// generated is set when references from generated files are discounted.
+	generated *generatedFiles
+}
+
+func newReferenceCounter(root string, rules Rules) referenceCounter {
+	rc := referenceCounter{root: root, exclusions: rules.excludeReferences}
+	if !rules.countGenerated {
+		rc.generated = rules.generated
+	}
+	return rc
+}
+
+// excludedBy returns the name of the first exclusion matching the reference.
+func (rc referenceCounter) excludedBy(reference lsp.Location) (string, bool) {
+	filePath, ok := strings.CutPrefix(reference.URI, rc.root+"/")
+	if !ok {
+		return "", false
+	}
+	for _, e := range rc.exclusions {
+		if matchPath(e.Paths, filePath) {
+			return e.Name, true
+		}
+	}
+	if rc.generated.is(filePath) {
+		return excludedGenerated, true
+	}
+	return "", false
+}
+
+func (rc referenceCounter) isUsed(references []lsp.Location, countTests bool) bool {
+	counts := rc.count(references)
+	if countTests {
+		return counts.Total > counts.Excluded
+	}
+	return counts.Total > counts.Test+counts.Excluded
+}
+
+func (rc referenceCounter) countsAsUse(reference lsp.Location, countTests bool) bool {
+	if !countTests && isTestFile(reference.URI) {
+		return false
+	}
+	_, excluded := rc.excludedBy(reference)
+	return !excluded
+}
+
+func (rc referenceCounter) count(references []lsp.Location) ReferenceCounts {
+	counts := ReferenceCounts{Total: len(references)}
 	for _, reference := range references {
-		if strings.HasSuffix(reference.URI, "_test.go") || strings.Contains(reference.URI, "mock") {
+		if isTestFile(reference.URI) {
+			counts.Test++
 			continue
 		}
-		return true
+		if name, ok := rc.excludedBy(reference); ok {
+			counts.Excluded++
+			if counts.ExcludedBy == nil {
+				counts.ExcludedBy = make(map[string]int)
+			}
+			counts.ExcludedBy[name]++
+		}
 	}
-	return false
+	return counts
+}
+
+// isTestFile reports whether a path or URI is a Go test file.
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, "_test.go")
 }
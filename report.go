@@ -0,0 +1,238 @@
+package deadweight
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+const ReportVersion = 1
+
+type Report struct {
+	Version         int              `json:"version"`
+	Findings        []Finding        `json:"findings"`
+	TestOnly        []Finding        `json:"testOnly,omitempty"`
+	TestHelpers     []Finding        `json:"testHelpers,omitempty"`
+	Generated       []Finding        `json:"generated,omitempty"`
+	Unknown         []Finding        `json:"unknown,omitempty"`
+	TimedOut        []Finding        `json:"timedOut,omitempty"`
+	FixedBaseline   []BaselineEntry  `json:"fixedBaseline,omitempty"`
+	StaleDirectives []StaleDirective `json:"staleDirectives,omitempty"`
+	// Interrupted is set when the analysis was cancelled, the report is then
+	// incomplete.
+	Interrupted bool `json:"interrupted,omitempty"`
+
+	// Symbols holds every analyzed symbol, used or not.
+	Symbols *SymbolMap `json:"-"`
+}
+
+type Finding struct {
+	File       string          `json:"file"`
+	Line       int             `json:"line"`
+	Column     int             `json:"column"`
+	Kind       string          `json:"kind"`
+	Name       string          `json:"name"`
+	Container  string          `json:"container,omitempty"`
+	Language   string          `json:"language"`
+	References ReferenceCounts `json:"references"`
+	Error      string          `json:"error,omitempty"`
+	// Severity is the SARIF level of the finding, warning when empty.
+	Severity string `json:"severity,omitempty"`
+	// TestOnly is set on the findings of the symbols only referenced from
+	// test files, when they are reported separately.
+	TestOnly bool `json:"-"`
+	// TestHelper is set on the findings of the symbols declared in test files.
+	TestHelper bool `json:"-"`
+	// Generated is set on the findings of generated files, when they are
+	// reported separately.
+	Generated bool `json:"-"`
+
+	Symbol Symbol `json:"-"`
+}
+
+func NewFinding(filePath string, symbol Symbol, references ReferenceCounts) Finding {
+	return Finding{
+		File:       filePath,
+		Line:       symbol.Position.Line + 1,
+		Column:     symbol.Position.Character + 1,
+		Kind:       symbol.Kind.String(),
+		Name:       symbol.Name,
+		Container:  symbol.Container,
+		Language:   symbol.Language,
+		References: references,
+		Symbol:     symbol,
+	}
+}
+
+func NewReport(references *ReferenceMap, unused *SymbolMap) Report {
+	findings := newFindings(references, unused)
+
+	defer references.Unlock()
+	references.Lock()
+
+	var unknown, timedOut []Finding
+	for filePath, symbols := range references.errors {
+		for symbol, err := range symbols {
+			f := NewFinding(filePath, symbol, ReferenceCounts{})
+			f.Error = err.Error()
+			var respErr *lsp.ResponseError
+			if errors.As(err, &respErr) && respErr.Code == lsp.ErrorCodeRequestCancelled {
+				timedOut = append(timedOut, f)
+				continue
+			}
+			unknown = append(unknown, f)
+		}
+	}
+	sortFindings(unknown)
+	sortFindings(timedOut)
+
+	return Report{
+		Version:  ReportVersion,
+		Findings: findings,
+		Unknown:  unknown,
+		TimedOut: timedOut,
+	}
+}
+
+// newFindings returns the sorted findings of the symbols.
+func newFindings(references *ReferenceMap, symbols *SymbolMap) []Finding {
+	defer references.Unlock()
+	defer symbols.Unlock()
+	references.Lock()
+	symbols.Lock()
+
+	findings := make([]Finding, 0, len(symbols.m))
+	for filePath, fileSymbols := range symbols.m {
+		for _, symbol := range fileSymbols {
+			findings = append(findings, NewFinding(filePath, symbol, references.counter.count(references.m[filePath][symbol])))
+		}
+	}
+	sortFindings(findings)
+	return findings
+}
+
+func sortFindings(findings []Finding) {
+	slices.SortFunc(findings, func(a, b Finding) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Column, b.Column),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+func (r *Report) ApplyBaseline(b Baseline) {
+	r.Findings, r.FixedBaseline = b.Filter(r.Findings)
+}
+
+// ApplyDiff keeps the findings relevant to the change. Only the symbols of the
+// diff are resolved, so a fixed baseline entry is kept when its symbol is
+// relevant or was removed from a changed file.
+func (r *Report) ApplyDiff(d Diff) {
+	r.Findings = d.Filter(r.Findings)
+	r.TestOnly = d.Filter(r.TestOnly)
+	r.TestHelpers = d.Filter(r.TestHelpers)
+	r.Generated = d.Filter(r.Generated)
+
+	if r.Symbols == nil {
+		r.FixedBaseline = nil
+		return
+	}
+	symbols := r.Symbols.snapshot()
+	fixed := r.FixedBaseline[:0]
+	for _, entry := range r.FixedBaseline {
+		i := slices.IndexFunc(symbols[entry.File], func(s Symbol) bool {
+			return s.Name == entry.Name && s.Container == entry.Container && s.Kind.String() == entry.Kind
+		})
+		if i >= 0 && d.Relevant(entry.File, symbols[entry.File][i]) {
+			fixed = append(fixed, entry)
+			continue
+		}
+		_, changed := d.Changed[entry.File]
+		if _, exists := symbols[entry.File]; i < 0 && (changed || !exists) {
+			fixed = append(fixed, entry)
+		}
+	}
+	r.FixedBaseline = fixed
+}
+
+func (r Report) Print(logger *slog.Logger) {
+	for _, f := range r.Findings {
+		f.Print(logger)
+	}
+}
+
+func (r Report) PrintTestOnly(logger *slog.Logger) {
+	for _, f := range r.TestOnly {
+		f.Print(logger)
+	}
+}
+
+func (r Report) PrintTestHelpers(logger *slog.Logger) {
+	for _, f := range r.TestHelpers {
+		f.Print(logger)
+	}
+}
+
+func (r Report) PrintGenerated(logger *slog.Logger) {
+	for _, f := range r.Generated {
+		f.Print(logger)
+	}
+}
+
+// Print logs the finding along with the references discounted per exclusion
+// rule.
+func (f Finding) Print(logger *slog.Logger) {
+	var excluded []any
+	for _, name := range slices.Sorted(maps.Keys(f.References.ExcludedBy)) {
+		excluded = append(excluded, slog.Int(name, f.References.ExcludedBy[name]))
+	}
+	var attrs []any
+	if len(excluded) > 0 {
+		attrs = append(attrs, slog.Group("excluded", excluded...))
+	}
+	logger.Info(fmt.Sprintf("%s (%s) %s:%d:%d", f.Name, f.Kind, f.File, f.Line, f.Column), attrs...)
+}
+
+func (r Report) PrintUnknown(logger *slog.Logger) {
+	for _, f := range slices.Concat(r.Unknown, r.TimedOut) {
+		logger.Warn(fmt.Sprintf("%s (%s) %s:%d:%d", f.Name, f.Kind, f.File, f.Line, f.Column), slog.String("error", f.Error))
+	}
+}
+
+func (r Report) PrintStaleDirectives(logger *slog.Logger) {
+	for _, d := range r.StaleDirectives {
+		directive := directiveIgnore
+		if d.IsFile {
+			directive = directiveIgnoreFile
+		}
+		logger.Warn(fmt.Sprintf("%s %s:%d", directive, d.File, d.Line))
+	}
+}
+
+func (r Report) PrintFixedBaseline(logger *slog.Logger) {
+	for _, entry := range r.FixedBaseline {
+		name := entry.Name
+		if entry.Container != "" {
+			name = entry.Container + "." + name
+		}
+		logger.Info(fmt.Sprintf("%s (%s) %s", name, entry.Kind, entry.File))
+	}
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package deadweight
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+func TestApplyBaselineWithDiff(t *testing.T) {
+	symbolAt := func(name string, line int) Symbol {
+		return goSymbol(name, lsp.SymbolKindFunction, lsp.Position{Line: line}, lsp.Position{Line: line, Character: 20}, lsp.Position{Line: line, Character: 5})
+	}
+	changed := symbolAt("Changed", 4)
+	stillUnused := symbolAt("StillUnused", 8)
+	outside := symbolAt("Outside", 2)
+
+	symbols := NewSymbolMap()
+	symbols.Store("a.go", []Symbol{changed, stillUnused})
+	symbols.Store("b.go", []Symbol{outside})
+
+	entry := func(file string, s Symbol) BaselineEntry {
+		return BaselineEntry{File: file, Name: s.Name, Kind: s.Kind.String()}
+	}
+	baseline := Baseline{Version: BaselineVersion, Entries: []BaselineEntry{
+		entry("a.go", changed),
+		entry("a.go", stillUnused),
+		entry("a.go", symbolAt("Removed", 0)),
+		entry("b.go", outside),
+		entry("b.go", symbolAt("RemovedOutside", 0)),
+		entry("deleted.go", symbolAt("Deleted", 0)),
+	}}
+	diff := Diff{
+		Changed:            map[string][]LineRange{"a.go": {{Start: 4, End: 4}, {Start: 8, End: 8}}},
+		RemovedIdentifiers: map[string]bool{"Removed": true},
+	}
+
+	// only the symbols of the diff are resolved: Changed became used and
+	// Outside was not looked up
+	report := Report{
+		Findings: []Finding{NewFinding("a.go", stillUnused, ReferenceCounts{})},
+		Symbols:  symbols,
+	}
+	report.ApplyBaseline(baseline)
+	report.ApplyDiff(diff)
+
+	if len(report.Findings) != 0 {
+		t.Errorf("Findings = %v, want none", report.Findings)
+	}
+	want := []BaselineEntry{
+		entry("a.go", changed),
+		entry("a.go", symbolAt("Removed", 0)),
+		entry("deleted.go", symbolAt("Deleted", 0)),
+	}
+	if !slices.Equal(report.FixedBaseline, want) {
+		t.Errorf("FixedBaseline = %v, want %v", report.FixedBaseline, want)
+	}
+}
@@ -0,0 +1,208 @@
+package deadweight
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	sarifVersion   = "2.1.0"
+	sarifSchema    = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSrcRootID = "%SRCROOT%"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log. root is the URI of the
+// analyzed directory, finding paths are made relative to it.
+func (r Report) WriteSARIF(w io.Writer, root string) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "deadweight",
+				InformationURI: "https://github.com/theo303/deadweight",
+				Version:        toolVersion(),
+				Rules:          []sarifRule{},
+			},
+		},
+		OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+			sarifSrcRootID: {URI: strings.TrimSuffix(root, "/") + "/"},
+		},
+		Results: make([]sarifResult, 0, len(r.Findings)+len(r.TestOnly)+len(r.TestHelpers)+len(r.Generated)),
+	}
+
+	ruleIndexes := make(map[string]int)
+	for _, f := range slices.Concat(r.Findings, r.TestOnly, r.TestHelpers, r.Generated) {
+		level := cmp.Or(f.Severity, "warning")
+		ruleID := f.RuleID()
+		index, ok := ruleIndexes[ruleID]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndexes[ruleID] = index
+			rule := sarifRule{
+				ID:                   ruleID,
+				Name:                 "Unused" + f.Kind,
+				ShortDescription:     sarifMessage{Text: fmt.Sprintf("Unused %s", strings.ToLower(f.Kind))},
+				DefaultConfiguration: sarifConfiguration{Level: level},
+			}
+			switch {
+			case f.TestOnly:
+				rule.Name = "TestOnly" + f.Kind
+				rule.ShortDescription.Text = fmt.Sprintf("%s only used by tests", f.Kind)
+			case f.TestHelper:
+				rule.Name = "UnusedTest" + f.Kind
+				rule.ShortDescription.Text = fmt.Sprintf("Unused test %s", strings.ToLower(f.Kind))
+			case f.Generated:
+				rule.Name = "UnusedGenerated" + f.Kind
+				rule.ShortDescription.Text = fmt.Sprintf("Unused generated %s", strings.ToLower(f.Kind))
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		message := fmt.Sprintf("%s %s is unused", f.Kind, f.Name)
+		if f.TestOnly {
+			message = fmt.Sprintf("%s %s is only used by tests", f.Kind, f.Name)
+		}
+		selection := f.Symbol.SelectionRange
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleID,
+			RuleIndex: index,
+			Level:     level,
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{
+						URI:       f.File,
+						URIBaseID: sarifSrcRootID,
+					},
+					Region: sarifRegion{
+						StartLine:   selection.Start.Line + 1,
+						StartColumn: selection.Start.Character + 1,
+						EndLine:     selection.End.Line + 1,
+						EndColumn:   selection.End.Character + 1,
+					},
+				},
+			}},
+			Properties: map[string]any{"language": f.Language},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}); err != nil {
+		return fmt.Errorf("failed to encode SARIF log: %w", err)
+	}
+	return nil
+}
+
+// RuleID identifies the kind of finding, like the rules of SARIF logs.
+func (f Finding) RuleID() string {
+	if f.TestOnly {
+		return sarifRuleID("test-only", f.Kind)
+	}
+	if f.TestHelper {
+		return sarifRuleID("unused-test", f.Kind)
+	}
+	if f.Generated {
+		return sarifRuleID("unused-generated", f.Kind)
+	}
+	return sarifRuleID("unused", f.Kind)
+}
+
+// sarifRuleID turns a symbol kind name into a rule ID, e.g. EnumMember
+// becomes unused-enum-member.
+func sarifRuleID(prefix, kind string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i, r := range kind {
+		if unicode.IsUpper(r) || i == 0 {
+			b.WriteRune('-')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "(devel)" {
+		return ""
+	}
+	return info.Main.Version
+}
@@ -0,0 +1,79 @@
+package deadweight
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type RequestStats struct {
+	Server      string        `json:"server"`
+	Sent        int           `json:"sent"`
+	Completed   int           `json:"completed"`
+	MaxInFlight int           `json:"maxInFlight"`
+	AvgLatency  time.Duration `json:"avgLatency"`
+	Elapsed     time.Duration `json:"elapsed"`
+}
+
+// Throughput returns the number of completed requests per second.
+func (rs RequestStats) Throughput() float64 {
+	if rs.Elapsed <= 0 {
+		return 0
+	}
+	return float64(rs.Completed) / rs.Elapsed.Seconds()
+}
+
+func (rs RequestStats) Print(logger *slog.Logger) {
+	logger.Info(fmt.Sprintf("%s: %d requests, %d in flight at most, %s average latency, %.1f requests/s",
+		rs.Server, rs.Completed, rs.MaxInFlight, rs.AvgLatency.Round(time.Microsecond), rs.Throughput(),
+	))
+}
+
+type requestStats struct {
+	start        time.Time
+	sent         int
+	completed    int
+	inFlight     int
+	maxInFlight  int
+	totalLatency time.Duration
+
+	sync.Mutex
+}
+
+func (s *requestStats) send() {
+	defer s.Unlock()
+	s.Lock()
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	s.sent++
+	s.inFlight++
+	s.maxInFlight = max(s.maxInFlight, s.inFlight)
+}
+
+func (s *requestStats) complete(latency time.Duration) {
+	defer s.Unlock()
+	s.Lock()
+	s.completed++
+	s.inFlight--
+	s.totalLatency += latency
+}
+
+func (s *requestStats) snapshot(server string) RequestStats {
+	defer s.Unlock()
+	s.Lock()
+	rs := RequestStats{
+		Server:      server,
+		Sent:        s.sent,
+		Completed:   s.completed,
+		MaxInFlight: s.maxInFlight,
+	}
+	if s.completed > 0 {
+		rs.AvgLatency = s.totalLatency / time.Duration(s.completed)
+	}
+	if !s.start.IsZero() {
+		rs.Elapsed = time.Since(s.start)
+	}
+	return rs
+}
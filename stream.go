@@ -0,0 +1,64 @@
+package deadweight
+
+import (
+	"sync"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+// findingStream passes the unused symbols to a callback as soon as their
+// references are resolved, dropping the ones the report would filter out.
+type findingStream struct {
+	onFinding  func(Finding)
+	diff       *Diff
+	counter    referenceCounter
+	countTests bool
+	// separate reports whether the findings of a file are reported apart, like
+	// the ones of test files, nil when no other file is.
+	separate func(filePath string) bool
+
+	mu sync.Mutex
+	// baseline counts the baseline entries not matched by a finding yet.
+	baseline map[BaselineEntry]int
+}
+
+func newFindingStream(onFinding func(Finding), baseline *Baseline, diff *Diff, counter referenceCounter, countTests bool) *findingStream {
+	fs := &findingStream{
+		onFinding:  onFinding,
+		diff:       diff,
+		counter:    counter,
+		countTests: countTests,
+		baseline:   make(map[BaselineEntry]int),
+	}
+	if baseline != nil {
+		for _, entry := range baseline.Entries {
+			fs.baseline[entry]++
+		}
+	}
+	return fs
+}
+
+func (fs *findingStream) resolved(filePath string, symbol Symbol, references []lsp.Location) {
+	// unused test helpers are reported separately
+	if isTestFile(filePath) || (fs.separate != nil && fs.separate(filePath)) {
+		return
+	}
+	// methods may be used through an interface, they are passed once the
+	// implementations are resolved
+	if symbol.Kind == lsp.SymbolKindMethod || fs.counter.isUsed(references, fs.countTests) {
+		return
+	}
+	if fs.diff != nil && !fs.diff.Relevant(filePath, symbol) {
+		return
+	}
+	f := NewFinding(filePath, symbol, fs.counter.count(references))
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	entry := newBaselineEntry(f)
+	if fs.baseline[entry] > 0 {
+		fs.baseline[entry]--
+		return
+	}
+	fs.onFinding(f)
+}
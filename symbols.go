@@ -3,15 +3,20 @@ package deadweight
 import (
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 
 	"github.com/theo303/deadweight/lsp"
 )
 
 type Symbol struct {
-	Position lsp.Position
-	Name     string
-	Kind     lsp.SymbolKind
+	Position       lsp.Position
+	Range          lsp.Range
+	SelectionRange lsp.Range
+	Name           string
+	Kind           lsp.SymbolKind
+	Container      string
+	Language       string
 
 	IsEmbeddedField bool
 }
@@ -20,23 +25,53 @@ func (s Symbol) String() string {
 	return "s"
 }
 
-func NewSymbol(documentSymbol lsp.DocumentSymbol) Symbol {
+func NewSymbol(documentSymbol lsp.DocumentSymbol, container string) Symbol {
 	return Symbol{
-		Position: documentSymbol.SelectionRange.Start,
-		Name:     documentSymbol.Name,
-		Kind:     documentSymbol.Kind,
+		Position:       documentSymbol.SelectionRange.Start,
+		Range:          documentSymbol.Range,
+		SelectionRange: documentSymbol.SelectionRange,
+		Name:           documentSymbol.Name,
+		Kind:           documentSymbol.Kind,
+		Container:      container,
 	}
 }
 
 type SymbolMap struct {
 	m map[string][]Symbol
+	// errors holds the symbols that could not be classified, like fields
+	// whose embedded probe failed.
+	errors map[string]map[Symbol]error
 
 	sync.Mutex
 }
 
 func NewSymbolMap() *SymbolMap {
 	return &SymbolMap{
-		m: make(map[string][]Symbol),
+		m:      make(map[string][]Symbol),
+		errors: make(map[string]map[Symbol]error),
+	}
+}
+
+func (sm *SymbolMap) StoreError(filePath string, symbol Symbol, err error) {
+	defer sm.Unlock()
+	sm.Lock()
+	if sm.errors[filePath] == nil {
+		sm.errors[filePath] = make(map[Symbol]error)
+	}
+	sm.errors[filePath][symbol] = err
+}
+
+// storeErrorsIn records the symbols that could not be classified as
+// unresolved in references.
+func (sm *SymbolMap) storeErrorsIn(references *ReferenceMap, keep func(filePath string, symbol Symbol) bool) {
+	defer sm.Unlock()
+	sm.Lock()
+	for filePath, symbols := range sm.errors {
+		for symbol, err := range symbols {
+			if keep(filePath, symbol) {
+				references.StoreError(filePath, symbol, err)
+			}
+		}
 	}
 }
 
@@ -55,23 +90,30 @@ func (sm *SymbolMap) Add(filepath string, symbol Symbol) {
 	sm.m[filepath] = append(sm.m[filepath], symbol)
 }
 
-func (sm *SymbolMap) Len() int {
-	if sm == nil {
-		return 0
+func (sm *SymbolMap) snapshot() map[string][]Symbol {
+	defer sm.Unlock()
+	sm.Lock()
+	m := make(map[string][]Symbol, len(sm.m))
+	for filePath, symbols := range sm.m {
+		m[filePath] = slices.Clone(symbols)
 	}
+	return m
+}
 
+// File returns the symbols of a file.
+func (sm *SymbolMap) File(filePath string) []Symbol {
 	defer sm.Unlock()
 	sm.Lock()
-	return len(sm.m)
+	return slices.Clone(sm.m[filePath])
 }
 
-func (sm *SymbolMap) Print() {
+func (sm *SymbolMap) Print(logger *slog.Logger) {
 	defer sm.Unlock()
 
 	sm.Lock()
 	for filePath, symbols := range sm.m {
 		for _, symbol := range symbols {
-			slog.Info(fmt.Sprintf("%s (%s) %s:%d:%d",
+			logger.Info(fmt.Sprintf("%s (%s) %s:%d:%d",
 				symbol.Name, symbol.Kind.String(), filePath, symbol.Position.Line+1, symbol.Position.Character+1,
 			))
 		}
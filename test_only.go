@@ -0,0 +1,59 @@
+package deadweight
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TestOnlyMode tells how symbols only referenced from test files are reported.
+type TestOnlyMode string
+
+const (
+	// TestOnlyUnused reports them as unused.
+	TestOnlyUnused TestOnlyMode = "unused"
+	// TestOnlyUsed considers them used.
+	TestOnlyUsed TestOnlyMode = "used"
+	// TestOnlySeparate reports them in their own category of the report.
+	TestOnlySeparate TestOnlyMode = "separate"
+)
+
+const defaultTestOnlySeverity = "note"
+
+// severities are the SARIF levels a finding can be reported with.
+var severities = []string{"error", "warning", "note", "none"}
+
+type TestOnlyPolicy struct {
+	Mode TestOnlyMode
+	// Severity is the SARIF level of the separate findings.
+	Severity string
+}
+
+func (p TestOnlyPolicy) validate() error {
+	if !slices.Contains([]TestOnlyMode{"", TestOnlyUnused, TestOnlyUsed, TestOnlySeparate}, p.Mode) {
+		return fmt.Errorf("unknown test-only mode %q", p.Mode)
+	}
+	if p.Severity != "" && !slices.Contains(severities, p.Severity) {
+		return fmt.Errorf("unknown test-only severity %q", p.Severity)
+	}
+	return nil
+}
+
+// countTests reports whether references from test files count as uses for
+// the findings of the report.
+func (p TestOnlyPolicy) countTests() bool {
+	return p.Mode == TestOnlyUsed || p.Mode == TestOnlySeparate
+}
+
+// without returns the symbols of sm that are not part of other.
+func (sm *SymbolMap) without(other *SymbolMap) *SymbolMap {
+	otherSymbols := other.snapshot()
+	result := NewSymbolMap()
+	for filePath, symbols := range sm.snapshot() {
+		for _, s := range symbols {
+			if !slices.Contains(otherSymbols[filePath], s) {
+				result.Add(filePath, s)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,192 @@
+package deadweight
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+const diffContext = 3
+
+type lineOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff returns the changes between two versions of a file in the
+// unified format, or an empty string when they are identical.
+func UnifiedDiff(filePath string, before, after []byte) string {
+	if bytes.Equal(before, after) {
+		return ""
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", filePath, filePath)
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// extend the hunk while changes are close enough to share context
+		end, unchanged := start, 0
+		for i := start; i < len(ops) && unchanged <= 2*diffContext; i++ {
+			if ops[i].kind == ' ' {
+				unchanged++
+				continue
+			}
+			end, unchanged = i+1, 0
+		}
+		first := max(start-diffContext, 0)
+		last := min(end+diffContext, len(ops))
+
+		oldStart, newStart := 1, 1
+		for _, op := range ops[:first] {
+			if op.kind != '+' {
+				oldStart++
+			}
+			if op.kind != '-' {
+				newStart++
+			}
+		}
+		oldLines, newLines := 0, 0
+		for _, op := range ops[first:last] {
+			if op.kind != '+' {
+				oldLines++
+			}
+			if op.kind != '-' {
+				newLines++
+			}
+		}
+		if oldLines == 0 {
+			oldStart--
+		}
+		if newLines == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLines, newStart, newLines)
+		for _, op := range ops[first:last] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = last
+	}
+	return sb.String()
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the shortest edit script between a and b with the Myers
+// algorithm.
+func diffLines(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		found := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+
+	var ops []lineOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var previousK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			previousK = k + 1
+		} else {
+			previousK = k - 1
+		}
+		previousX := v[offset+previousK]
+		previousY := previousX - previousK
+		for x > previousX && y > previousY {
+			x--
+			y--
+			ops = append(ops, lineOp{kind: ' ', line: a[x]})
+		}
+		if d > 0 {
+			if x == previousX {
+				y--
+				ops = append(ops, lineOp{kind: '+', line: b[y]})
+			} else {
+				x--
+				ops = append(ops, lineOp{kind: '-', line: a[x]})
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// LineEdits returns the edits turning before into after, each of them
+// replacing whole lines.
+func LineEdits(before, after []byte) []lsp.TextEdit {
+	var edits []lsp.TextEdit
+	ops := diffLines(splitLines(before), splitLines(after))
+	line := 0
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			line++
+			i++
+			continue
+		}
+		start := line
+		var newText strings.Builder
+		for ; i < len(ops) && ops[i].kind != ' '; i++ {
+			if ops[i].kind == '-' {
+				line++
+			} else {
+				newText.WriteString(ops[i].line)
+			}
+		}
+		edits = append(edits, lsp.TextEdit{
+			Range: lsp.Range{
+				Start: lsp.Position{Line: start},
+				End:   lsp.Position{Line: line},
+			},
+			NewText: newText.String(),
+		})
+	}
+	return edits
+}
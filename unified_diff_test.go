@@ -0,0 +1,113 @@
+package deadweight
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/theo303/deadweight/lsp"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "identical",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "changed line",
+			before: "a\nb\nc\n",
+			after:  "a\nB\nc\n",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:   "removed content",
+			before: "a\n",
+			after:  "",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name:   "no newline at end of file",
+			before: "a",
+			after:  "b",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name:   "distant changes",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			after:  "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			want: "--- a/f.go\n+++ b/f.go\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n" +
+				"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+		{
+			name:   "close changes",
+			before: "1\n2\n3\n4\n5\n",
+			after:  "x\n2\n3\n4\ny\n",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n 4\n-5\n+y\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := UnifiedDiff("f.go", []byte(tc.before), []byte(tc.after)); got != tc.want {
+				t.Errorf("UnifiedDiff() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	lines := func(start, end int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: start}, End: lsp.Position{Line: end}}
+	}
+	for _, tc := range []struct {
+		name   string
+		before string
+		after  string
+		want   []lsp.TextEdit
+	}{
+		{
+			name:   "identical",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+		},
+		{
+			name:   "changed line",
+			before: "a\nb\nc\n",
+			after:  "a\nB\nc\n",
+			want:   []lsp.TextEdit{{Range: lines(1, 2), NewText: "B\n"}},
+		},
+		{
+			name:   "removed lines",
+			before: "a\nb\nc\nd\n",
+			after:  "a\nd\n",
+			want:   []lsp.TextEdit{{Range: lines(1, 3), NewText: ""}},
+		},
+		{
+			name:   "inserted line",
+			before: "a\n",
+			after:  "a\nb\n",
+			want:   []lsp.TextEdit{{Range: lines(1, 1), NewText: "b\n"}},
+		},
+		{
+			name:   "several changes",
+			before: "a\nb\nc\nd\n",
+			after:  "b\nc\nD\n",
+			want: []lsp.TextEdit{
+				{Range: lines(0, 1), NewText: ""},
+				{Range: lines(3, 4), NewText: "D\n"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := LineEdits([]byte(tc.before), []byte(tc.after)); !slices.Equal(got, tc.want) {
+				t.Errorf("LineEdits() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
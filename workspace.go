@@ -0,0 +1,176 @@
+package deadweight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Workspace drives one language server per language and routes every file to
+// the server handling its extension.
+type Workspace struct {
+	clients []*lspClient
+	root    string
+	rules   Rules
+}
+
+func NewWorkspace(ctx context.Context, root string, servers []LanguageServer, rules Rules, options ClientOptions) (*Workspace, error) {
+	w := &Workspace{root: root, rules: rules}
+	for _, server := range servers {
+		lc, err := NewLSPClient(ctx, root, server, rules, options)
+		if err != nil {
+			return nil, fmt.Errorf("language server '%s': %w", server.Name, err)
+		}
+		w.clients = append(w.clients, lc)
+	}
+	return w, nil
+}
+
+func (w *Workspace) RunAndInitialize(ctx context.Context) error {
+	errs := make([]error, len(w.clients))
+	wg := sync.WaitGroup{}
+	for i, lc := range w.clients {
+		wg.Go(func() {
+			if err := lc.RunAndInitialize(ctx); err != nil {
+				errs[i] = fmt.Errorf("language server '%s': %w", lc.server.Name, err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (w *Workspace) client(filePath string) *lspClient {
+	for _, lc := range w.clients {
+		if lc.server.HandlesFile(filePath) {
+			return lc
+		}
+	}
+	return nil
+}
+
+func (w *Workspace) ListDocumentSymbols(ctx context.Context, files []string) (*SymbolMap, error) {
+	symbols := NewSymbolMap()
+	wg := &sync.WaitGroup{}
+
+	var errsMu sync.Mutex
+	var errs []error
+	for _, file := range files {
+		lc := w.client(file)
+		if lc == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			if err := lc.ListDocumentSymbols(ctx, file, wg, symbols); err != nil {
+				errsMu.Lock()
+				errs = append(errs, err)
+				errsMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return symbols, errors.Join(errs...)
+}
+
+// ReferencesSymbols resolves the references of the symbols, onResolved is
+// called with each of them as soon as they are resolved when not nil, possibly
+// concurrently.
+func (w *Workspace) ReferencesSymbols(ctx context.Context, symbols *SymbolMap, onResolved ReferencesFunc) (*ReferenceMap, error) {
+	references := NewReferenceMap()
+	references.counter = newReferenceCounter(w.root, w.rules)
+	errs := make([]error, len(w.clients))
+	wg := sync.WaitGroup{}
+	for i, lc := range w.clients {
+		wg.Go(func() {
+			clientReferences, err := lc.ReferencesSymbols(ctx, symbols, onResolved)
+			if err != nil {
+				errs[i] = fmt.Errorf("language server '%s': %w", lc.server.Name, err)
+				return
+			}
+			references.Merge(clientReferences)
+		})
+	}
+	wg.Wait()
+	return references, errors.Join(errs...)
+}
+
+// ImplementationsSymbols stores in references the interface methods
+// implemented by the methods.
+func (w *Workspace) ImplementationsSymbols(ctx context.Context, methods *SymbolMap, references *ReferenceMap) error {
+	errs := make([]error, len(w.clients))
+	wg := sync.WaitGroup{}
+	for i, lc := range w.clients {
+		wg.Go(func() {
+			if err := lc.ImplementationsSymbols(ctx, methods, references); err != nil {
+				errs[i] = fmt.Errorf("language server '%s': %w", lc.server.Name, err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Err returns an error for every server that exited without being shut down.
+func (w *Workspace) Err() error {
+	var errs []error
+	for _, lc := range w.clients {
+		errs = append(errs, lc.Err())
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Workspace) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(w.clients))
+	wg := sync.WaitGroup{}
+	for i, lc := range w.clients {
+		wg.Go(func() {
+			errs[i] = lc.Shutdown(ctx)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (w *Workspace) Stats() []RequestStats {
+	stats := make([]RequestStats, 0, len(w.clients))
+	for _, lc := range w.clients {
+		stats = append(stats, lc.Stats())
+	}
+	return stats
+}
+
+// SetRules replaces the rules deciding which symbols are analyzed, for the
+// next listing of document symbols.
+func (w *Workspace) SetRules(rules Rules) {
+	w.rules = rules
+	for _, lc := range w.clients {
+		lc.SetRules(rules)
+	}
+}
+
+// SetCache replaces the cache used to skip requests, nil disables it.
+func (w *Workspace) SetCache(cache *Cache) {
+	for _, lc := range w.clients {
+		lc.SetCache(cache)
+	}
+}
+
+// UpdateDocument sends the content of a file being edited to its server.
+func (w *Workspace) UpdateDocument(filePath string, content []byte) error {
+	lc := w.client(filePath)
+	if lc == nil {
+		return nil
+	}
+	return lc.UpdateDocument(filePath, content)
+}
+
+func (w *Workspace) CloseDocument(filePath string) error {
+	lc := w.client(filePath)
+	if lc == nil {
+		return nil
+	}
+	return lc.CloseDocument(filePath)
+}